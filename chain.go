@@ -0,0 +1,187 @@
+package main
+
+// This file defines the splitting of method chains whose intermediate calls
+// return errors, e.g. client.Connect(addr).Login(user) → c, err :=
+// client.Connect(addr); if err != nil { … }; c.Login(user).
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// methodChain returns the calls of the method chain ending in ce (innermost
+// first) along with their signatures, or nil if no intermediate call returns a
+// value and an error. As such chains do not type-check, the signatures of the
+// calls following an intermediate call are derived from its result type.
+func (e *expansion) methodChain(ce *ast.CallExpr) ([]*ast.CallExpr, []*types.Signature, error) {
+	calls := []*ast.CallExpr{ce}
+	for {
+		sel, ok := unparen(calls[0].Fun).(*ast.SelectorExpr)
+		if !ok {
+			break
+		}
+		inner, ok := unparen(sel.X).(*ast.CallExpr)
+		if !ok {
+			break
+		}
+		calls = append([]*ast.CallExpr{inner}, calls...)
+	}
+	if len(calls) < 2 {
+		return nil, nil, nil
+	}
+
+	sigs := make([]*types.Signature, len(calls))
+	var err error
+	sigs[0], err = signatureOf(e.info, calls[0])
+	if err == errUnknownSignature {
+		return nil, nil, err
+	}
+	if err != nil {
+		return nil, nil, nil // reported when expanding ce itself
+	}
+	for idx := 1; idx < len(calls); idx++ {
+		prev := sigs[idx-1].Results()
+		if prev.Len() != 2 || !isError(prev.At(1).Type()) {
+			if sig, err := signatureOf(e.info, calls[idx]); err == nil {
+				// This part of the chain type-checks.
+				sigs[idx] = sig
+				continue
+			}
+			return nil, nil, nil
+		}
+		sel := unparen(calls[idx].Fun).(*ast.SelectorExpr)
+		obj, _, _ := types.LookupFieldOrMethod(prev.At(0).Type(), true, e.pkg, sel.Sel.Name)
+		if obj == nil {
+			return nil, nil, fmt.Errorf("%s has no field or method %s", types.TypeString(prev.At(0).Type(), e.messageQualifier), sel.Sel.Name)
+		}
+		sig, ok := obj.Type().Underlying().(*types.Signature)
+		if !ok {
+			return nil, nil, fmt.Errorf("cannot call %s: not a function (type %s)", sel.Sel.Name, obj.Type())
+		}
+		sigs[idx] = sig
+	}
+	for _, sig := range sigs[:len(sigs)-1] {
+		if sig.Results().Len() == 2 {
+			return calls, sigs, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// expandChain splits the method chain e.chain into one assignment and error
+// check per call which returns a value and an error. The intermediate values
+// are named after their types. The last call is checked, too, if it is a
+// statement or assigned.
+func (e *expansion) expandChain(noReturnStr string) (ast.Node, []ast.Node, error) {
+	stmt, err := e.enclosingStmt()
+	if err != nil {
+		return nil, nil, err
+	}
+	scope := e.getScope()
+	if scope == nil {
+		return nil, nil, fmt.Errorf("could not find scope")
+	}
+
+	errName, assign := e.errVar(scope, stmt, false)
+	taken := map[string]bool{errName: true}
+	outer := e.ce
+	defer func() { e.ce = outer }()
+	check := func(ce *ast.CallExpr) (*ast.IfStmt, error) {
+		e.ce = ce // for the context of wrapped errors
+		outputStmt, err := e.getFinalOutput(noReturnStr, errName)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.IfStmt{
+			Cond: &ast.BinaryExpr{
+				X:  &ast.Ident{Name: errName},
+				Op: token.NEQ,
+				Y:  &ast.Ident{Name: "nil"},
+			},
+			Body: &ast.BlockStmt{List: outputStmt},
+		}, nil
+	}
+
+	var repl []ast.Node
+	var x ast.Expr // the value of the previous call
+	var last *ast.CallExpr
+	for idx, ce := range e.chain {
+		if x != nil {
+			// Copy instead of modifying ce, which is retained by e.path.
+			sel := unparen(ce.Fun).(*ast.SelectorExpr)
+			ce = &ast.CallExpr{
+				Fun:      &ast.SelectorExpr{X: x, Sel: sel.Sel},
+				Args:     ce.Args,
+				Ellipsis: ce.Ellipsis,
+			}
+		}
+		results := e.chainSigs[idx].Results()
+		if idx == len(e.chain)-1 {
+			last = ce
+			break
+		}
+		if results.Len() == 1 {
+			x = ce
+			continue
+		}
+		// e.g. c, err := client.Connect(addr); if err != nil { … }
+		name := freshName(scope, nameForType(results.At(0).Type()), stmt.Pos(), taken)
+		taken[name] = true
+		ifStmt, err := check(ce)
+		if err != nil {
+			return nil, nil, err
+		}
+		as, err := e.assignWithErr(scope, []ast.Expr{&ast.Ident{Name: name}}, results, errName, assign, ce)
+		if err != nil {
+			return nil, nil, err
+		}
+		repl = append(append(repl, as...), ifStmt)
+		x = &ast.Ident{Name: name}
+	}
+
+	results := e.chainSigs[len(e.chainSigs)-1].Results()
+	if _, ok := errorResult(e.chainSigs[len(e.chainSigs)-1]); ok {
+		switch parent := e.parent(outer).(type) {
+		case *ast.ExprStmt:
+			// e.g. s.Fetch(id) → if err := s.Fetch(id); err != nil { … }
+			ifStmt, err := check(last)
+			if err != nil {
+				return nil, nil, err
+			}
+			as, err := e.assignWithErr(scope, e.resultNames(scope, parent, results), results, errName, assign, last)
+			if err != nil {
+				return nil, nil, err
+			}
+			init := as[len(as)-1].(*ast.AssignStmt)
+			for _, lhs := range init.Lhs[:len(init.Lhs)-1] {
+				if lhs.(*ast.Ident).Name != "_" {
+					return stmt, append(append(repl, as...), ifStmt), nil
+				}
+			}
+			ifStmt.Init = init
+			return stmt, append(repl, ifStmt), nil
+
+		case *ast.AssignStmt:
+			// e.g. item := s.Fetch(id) → item, err := s.Fetch(id); if err != nil { … }
+			if len(parent.Rhs) == 1 && len(parent.Lhs) == results.Len()-1 {
+				ifStmt, err := check(last)
+				if err != nil {
+					return nil, nil, err
+				}
+				return stmt, append(repl,
+					&ast.AssignStmt{
+						Lhs: append(parent.Lhs[:len(parent.Lhs):len(parent.Lhs)], &ast.Ident{Name: errName}),
+						Tok: parent.Tok,
+						Rhs: []ast.Expr{last},
+					},
+					ifStmt), nil
+			}
+		}
+	}
+
+	// The last call is nested within another expression, so keep the
+	// statement, using the last value of the chain.
+	return stmt, append(repl, replaceExpr(stmt, outer, last)), nil
+}
@@ -0,0 +1,226 @@
+package main
+
+// This file defines the expansion of comma-ok expressions: map index
+// expressions, type assertions, channel receives and calls of functions whose
+// last result is a bool.
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+// commaOkExprAtPath returns the map index expression, type assertion or
+// channel receive under the cursor, unless the cursor is within a call
+// expression.
+func (e *expansion) commaOkExprAtPath() ast.Expr {
+	for _, n := range e.path {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			return nil
+		case *ast.IndexExpr:
+			if typ := e.info.TypeOf(n.X); typ != nil {
+				if _, ok := typ.Underlying().(*types.Map); ok {
+					return n
+				}
+			}
+		case *ast.TypeAssertExpr:
+			if n.Type != nil { // not in a type switch
+				return n
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				return n
+			}
+		case ast.Stmt:
+			return nil
+		}
+	}
+	return nil
+}
+
+// isCommaOkCall returns whether the last result of the callee is a bool, e.g.
+// os.LookupEnv.
+func (e *expansion) isCommaOkCall() bool {
+	results := e.callee.Results()
+	if results.Len() == 0 {
+		return false
+	}
+	basic, ok := results.At(results.Len() - 1).Type().Underlying().(*types.Basic)
+	return ok && basic.Kind() == types.Bool
+}
+
+// commaOkValues returns the values of the comma-ok expression x, not
+// including the trailing bool.
+func (e *expansion) commaOkValues(x ast.Expr) ([]*types.Var, error) {
+	var typ types.Type
+	switch x := x.(type) {
+	case *ast.IndexExpr:
+		typ = e.info.TypeOf(x.X).Underlying().(*types.Map).Elem()
+	case *ast.TypeAssertExpr:
+		typ = e.info.TypeOf(x.Type)
+	case *ast.UnaryExpr:
+		if ch, ok := e.info.TypeOf(x.X).Underlying().(*types.Chan); ok {
+			typ = ch.Elem()
+		}
+	case *ast.CallExpr:
+		results := e.callee.Results()
+		values := make([]*types.Var, results.Len()-1)
+		for idx := range values {
+			values[idx] = results.At(idx)
+		}
+		return values, nil
+	}
+	if typ == nil {
+		return nil, fmt.Errorf("could not determine the type of %s", types.ExprString(x))
+	}
+	return []*types.Var{types.NewVar(token.NoPos, nil, "", typ)}, nil
+}
+
+// simpleExpr returns whether x can be evaluated a second time without side
+// effects or noticeable cost.
+func simpleExpr(x ast.Expr) bool {
+	switch x := unparen(x).(type) {
+	case *ast.Ident, *ast.BasicLit:
+		return true
+	case *ast.SelectorExpr:
+		return simpleExpr(x.X)
+	}
+	return false
+}
+
+// notOkErr returns an expression constructing an error which describes why
+// the comma-ok expression x was not ok, e.g. fmt.Errorf("key %q not found", k).
+func (e *expansion) notOkErr(x ast.Expr) ast.Expr {
+	var format string
+	var args []ast.Expr
+	switch x := x.(type) {
+	case *ast.IndexExpr:
+		format = "key not found"
+		if simpleExpr(x.Index) {
+			verb := "%v"
+			if typ := e.info.TypeOf(x.Index); typ != nil && formatVerb(typ) != "" {
+				verb = formatVerb(typ)
+			}
+			format = "key " + verb + " not found"
+			args = append(args, x.Index)
+		}
+	case *ast.TypeAssertExpr:
+		format = "unexpected type, want " + types.ExprString(x.Type)
+		if simpleExpr(x.X) {
+			format = "unexpected type %T, want " + types.ExprString(x.Type)
+			args = append(args, x.X)
+		}
+	case *ast.UnaryExpr:
+		format = "receiving from " + types.ExprString(x.X) + ": channel closed"
+	case *ast.CallExpr:
+		msg, msgArgs := e.wrapContext()
+		if msg == "" {
+			msg = "call"
+		}
+		format = msg + " failed"
+		args = msgArgs
+	}
+	return &ast.CallExpr{
+		Fun: e.qualify("fmt", "Errorf"),
+		Args: append([]ast.Expr{
+			&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(format)},
+		}, args...),
+	}
+}
+
+// expandCommaOk expands the comma-ok expression x, e.g. v := m[k] → v, ok :=
+// m[k]; if !ok { return 0, fmt.Errorf("key %q not found", k) }. It returns the
+// node to be replaced and its replacement.
+func (e *expansion) expandCommaOk(noReturnStr string, x ast.Expr) (ast.Node, []ast.Node, error) {
+	scope := e.getScope()
+	if scope == nil {
+		return nil, nil, fmt.Errorf("could not find scope")
+	}
+	values, err := e.commaOkValues(x)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	errName := "err"
+	if named := e.namedErrorResult(); named != nil {
+		errName = named.Name()
+	}
+	failure, err := e.failureOutput(noReturnStr, errName, e.notOkErr(x))
+	if err != nil {
+		return nil, nil, err
+	}
+	check := func(cond ast.Expr) *ast.IfStmt {
+		return &ast.IfStmt{
+			Cond: &ast.UnaryExpr{Op: token.NOT, X: cond},
+			Body: &ast.BlockStmt{List: failure},
+		}
+	}
+
+	okName := "ok"
+	switch parent := e.parent(x).(type) {
+	case *ast.AssignStmt:
+		if len(parent.Rhs) != 1 {
+			return nil, nil, fmt.Errorf("cannot expand %s: assignment of multiple values", types.ExprString(x))
+		}
+		if parent.Rhs[0] != x {
+			// e.g. m[k] = v
+			return nil, nil, fmt.Errorf("cannot expand %s: it is assigned to, not assigned from", types.ExprString(x))
+		}
+		switch len(parent.Lhs) {
+		case len(values):
+			parent.Lhs = append(parent.Lhs, &ast.Ident{Name: okName})
+		case len(values) + 1:
+			id, ok := parent.Lhs[len(values)].(*ast.Ident)
+			if !ok {
+				return nil, nil, fmt.Errorf("cannot expand %s: %s is not a variable", types.ExprString(x), types.ExprString(parent.Lhs[len(values)]))
+			}
+			if id.Name == "_" {
+				id.Name = okName
+			}
+			okName = id.Name
+		default:
+			return nil, nil, fmt.Errorf("assignment mismatch: %d variables but %s has %d values", len(parent.Lhs), types.ExprString(x), len(values)+1)
+		}
+		repl := []ast.Node{parent, check(&ast.Ident{Name: okName})}
+		if parent.Tok != token.DEFINE && lookup(scope, okName, parent.Pos()) == nil {
+			repl = append([]ast.Node{&ast.DeclStmt{
+				Decl: &ast.GenDecl{
+					Tok: token.VAR,
+					Specs: []ast.Spec{
+						&ast.ValueSpec{
+							Names: []*ast.Ident{{Name: okName}},
+							Type:  &ast.Ident{Name: "bool"},
+						},
+					},
+				},
+			}}, repl...)
+		}
+		return parent, repl, nil
+
+	case *ast.ExprStmt:
+		if len(values) == 0 {
+			// e.g. check(x) → if !check(x) { … }
+			return parent, []ast.Node{check(x)}, nil
+		}
+		results := types.NewTuple(append(values, types.NewVar(token.NoPos, nil, okName, types.Typ[types.Bool]))...)
+		names := e.resultNames(scope, parent, results)
+		as := &ast.AssignStmt{
+			Lhs: append(names, &ast.Ident{Name: okName}),
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{x},
+		}
+		for _, name := range names {
+			if name.(*ast.Ident).Name != "_" {
+				return parent, []ast.Node{as, check(&ast.Ident{Name: okName})}, nil
+			}
+		}
+		// None of the values are used, so scope ok to the *ast.IfStmt.
+		stmt := check(&ast.Ident{Name: okName})
+		stmt.Init = as
+		return parent, []ast.Node{stmt}, nil
+	}
+	return nil, nil, fmt.Errorf("cannot expand %s: it must be assigned or be a statement to use the comma-ok form", types.ExprString(x))
+}
@@ -0,0 +1,199 @@
+package main
+
+// This file defines the expansion of calls within var declarations, e.g. var
+// cfg = load() → var cfg, err = load(); if err != nil { … }.
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+)
+
+// valueSpec returns the var declaration statement and its value spec whose
+// only value is e.ce if e.ce returns an error, nil otherwise.
+func (e *expansion) valueSpec() (*ast.DeclStmt, *ast.ValueSpec) {
+	if _, ok := errorResult(e.callee); !ok {
+		return nil, nil
+	}
+	spec, ok := e.parent(e.ce).(*ast.ValueSpec)
+	if !ok || len(spec.Values) != 1 {
+		return nil, nil
+	}
+	gen, ok := e.parent(spec).(*ast.GenDecl)
+	if !ok || gen.Tok != token.VAR {
+		return nil, nil
+	}
+	ds, ok := e.parent(gen).(*ast.DeclStmt)
+	if !ok {
+		return nil, nil // declared at package level
+	}
+	return ds, spec
+}
+
+// expandDecl expands e.ce within the var declaration spec, declared by ds.
+// Untyped declarations are extended by the error variable, e.g. var cfg, err =
+// load(). Typed declarations, and declarations of variables which must be
+// assigned, are split, e.g. var n int64 = f() → var n int64; var err error;
+// n, err = f(), unless they declare only the error, e.g. var e error =
+// os.Remove(p). Other specs of a grouped declaration are kept as-is (see
+// splitGroup).
+func (e *expansion) expandDecl(noReturnStr string, ds *ast.DeclStmt, spec *ast.ValueSpec) (ast.Node, []ast.Node, error) {
+	if err := e.checkErrorTypes(); err != nil {
+		return nil, nil, err
+	}
+	scope := e.getScope()
+	lhs := make([]ast.Expr, len(spec.Names))
+	for idx, name := range spec.Names {
+		lhs[idx] = name
+	}
+	slot, err := e.errorSlot(lhs)
+	if err != nil {
+		return nil, nil, err
+	}
+	errName, assign, err := e.errorSlotName(scope, ds, slot, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if slot != nil {
+		lhs = lhs[:len(lhs)-1]
+	}
+	names := append(lhs[:len(lhs):len(lhs)], &ast.Ident{Name: errName})
+	obj := lookup(scope, errName, ds.Pos())
+	declareErr := !assign && (obj == nil || obj.Parent() != scope)
+
+	var repl []ast.Node
+	if (spec.Type == nil || len(lhs) == 0) && declareErr {
+		// e.g. var cfg = load() → var cfg, err = load(), or
+		// var e error = os.Remove(p), which declares only the error
+		repl = append(repl, varDecl(&ast.ValueSpec{
+			Names:  identsOf(names),
+			Type:   spec.Type,
+			Values: []ast.Expr{e.ce},
+		}))
+	} else {
+		// e.g. var n int64 = f() → var n int64; n, err = f()
+		results := e.callee.Results()
+		if spec.Type != nil && len(lhs) > 0 {
+			repl = append(repl, varDecl(&ast.ValueSpec{Names: identsOf(lhs), Type: spec.Type}))
+		} else {
+			for idx, id := range identsOf(lhs) {
+				if id.Name == "_" {
+					continue
+				}
+				typeExpr, err := parser.ParseExpr(types.TypeString(results.At(idx).Type(), e.qualifier))
+				if err != nil {
+					return nil, nil, err
+				}
+				repl = append(repl, varDecl(&ast.ValueSpec{Names: []*ast.Ident{id}, Type: typeExpr}))
+			}
+		}
+		if declareErr {
+			var errType ast.Expr = &ast.Ident{Name: "error"}
+			if calleeErr, _ := errorResult(e.callee); !types.IsInterface(calleeErr) {
+				if expr, err := parser.ParseExpr(types.TypeString(calleeErr, e.qualifier)); err == nil {
+					errType = expr
+				}
+			}
+			repl = append(repl, varDecl(&ast.ValueSpec{
+				Names: []*ast.Ident{{Name: errName}},
+				Type:  errType,
+			}))
+		}
+		repl = append(repl, &ast.AssignStmt{
+			Lhs: names,
+			Tok: token.ASSIGN,
+			Rhs: []ast.Expr{e.ce},
+		})
+	}
+
+	outputStmt, err := e.getFinalOutput(noReturnStr, errName)
+	if err != nil {
+		return nil, nil, err
+	}
+	repl = append(repl, &ast.IfStmt{
+		Cond: &ast.BinaryExpr{
+			X:  &ast.Ident{Name: errName},
+			Op: token.NEQ,
+			Y:  &ast.Ident{Name: "nil"},
+		},
+		Body: &ast.BlockStmt{List: outputStmt},
+	})
+	repl = e.requireNoError(errName, repl)
+
+	if gen := ds.Decl.(*ast.GenDecl); gen.Lparen.IsValid() {
+		return e.splitGroup(gen, spec, repl)
+	}
+	return ds, repl, nil
+}
+
+// splitGroup places repl, the expansion of spec within the grouped declaration
+// gen, by closing the group before spec and reopening it after the error
+// check. Only spec itself is replaced, so that the other specs keep their
+// comments, e.g. var ( a = 1 // one; cfg = load(); b = 2 ) → var ( a = 1 //
+// one ); var cfg, err = load(); if err != nil { … }; var ( b = 2 ).
+func (e *expansion) splitGroup(gen *ast.GenDecl, spec *ast.ValueSpec, repl []ast.Node) (ast.Node, []ast.Node, error) {
+	idx := 0
+	for gen.Specs[idx] != spec {
+		idx++
+	}
+	// Splitting the group may realign the other specs, so the edits cover
+	// the whole group.
+	e.addEdit(edit{pos: gen.Lparen, end: gen.Lparen, text: ""})
+	e.addEdit(edit{pos: gen.Rparen + 1, end: gen.Rparen + 1, text: ""})
+
+	start := spec.Pos()
+	if spec.Doc != nil {
+		start = spec.Doc.Pos()
+	}
+	if idx == 0 {
+		e.addEdit(edit{pos: gen.Pos(), end: start, text: ""})
+	} else {
+		e.addEdit(edit{pos: start, end: start, text: ")\n"})
+	}
+
+	// The declaration replaces spec, the error check follows the line
+	// comment of spec.
+	split := len(repl)
+	for split > 0 && !containsNode(repl[split-1], e.ce) {
+		split--
+	}
+	text, err := e.formatNodes(repl[split:]...)
+	if err != nil {
+		return nil, nil, err
+	}
+	end := spec.End()
+	if spec.Comment != nil {
+		end = spec.Comment.End()
+	}
+	if idx == len(gen.Specs)-1 {
+		e.addEdit(edit{pos: end, end: gen.Rparen + 1, text: "\n" + text})
+	} else {
+		e.addEdit(edit{pos: end, end: end, text: "\n" + text + "\nvar ("})
+	}
+	return span{spec.Pos(), spec.End()}, repl[:split], nil
+}
+
+// containsNode returns whether x is n or one of its descendants.
+func containsNode(n, x ast.Node) bool {
+	found := false
+	ast.Inspect(n, func(c ast.Node) bool {
+		found = found || c == x
+		return !found
+	})
+	return found
+}
+
+// varDecl returns a var declaration of spec.
+func varDecl(spec ast.Spec) *ast.DeclStmt {
+	return &ast.DeclStmt{Decl: &ast.GenDecl{Tok: token.VAR, Specs: []ast.Spec{spec}}}
+}
+
+// identsOf returns exprs, which must all be identifiers, as identifiers.
+func identsOf(exprs []ast.Expr) []*ast.Ident {
+	ids := make([]*ast.Ident, len(exprs))
+	for idx, expr := range exprs {
+		ids[idx] = expr.(*ast.Ident)
+	}
+	return ids
+}
@@ -0,0 +1,269 @@
+package main
+
+// This file defines the expansion of deferred calls returning an error, e.g.
+// defer f.Close(), into a deferred function literal which captures the error
+// in the caller’s named error result.
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"go/version"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// deferStmt returns the defer statement of e.ce, if any.
+func (e *expansion) deferStmt() *ast.DeferStmt {
+	if e.ce == nil {
+		return nil
+	}
+	if ds, ok := e.parent(e.ce).(*ast.DeferStmt); ok && ds.Call == e.ce {
+		return ds
+	}
+	return nil
+}
+
+// moduleGoVersion returns the go version in effect for dir (e.g. "go1.21"):
+// the one declared by the go.work file of the workspace containing dir if
+// there is one, otherwise the one declared by the go.mod file of the module
+// containing dir, or "" if there is neither.
+func moduleGoVersion(dir string) string {
+	if work := workFile(dir); work != "" {
+		v, _ := goDirective(work)
+		return v
+	}
+	for {
+		if v, err := goDirective(filepath.Join(dir, "go.mod")); err == nil {
+			return v
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// workFile returns the path of the go.work file governing dir, or "" if
+// there is none. Like the go command, it honors the GOWORK environment
+// variable.
+func workFile(dir string) string {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return ""
+	case "", "auto":
+	default:
+		return gowork
+	}
+	for {
+		path := filepath.Join(dir, "go.work")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// goDirective returns the version declared by the go directive of the go.mod
+// or go.work file at path (e.g. "go1.21"), or "" if there is none. The file
+// is closed before goDirective returns.
+func goDirective(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "go" {
+			return "go" + fields[1], nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// canJoinErrors returns whether errors.Join can be used in the file under
+// cursor: it must be available in the module’s Go version and “errors” must
+// not refer to a different package.
+func (e *expansion) canJoinErrors() bool {
+	v := moduleGoVersion(filepath.Dir(e.fset.Position(e.file.Pos()).Filename))
+	if !version.IsValid(v) || version.Compare(v, "go1.20") < 0 {
+		return false
+	}
+	for _, imp := range e.file.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil || p == "errors" {
+			continue
+		}
+		name := path.Base(p)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if name == "errors" {
+			return false // e.g. github.com/pkg/errors
+		}
+	}
+	return true
+}
+
+// declaredAlongside returns whether obj is only declared by a “:=” statement
+// which declares other variables as well, e.g. f, err := os.Create(p). Once a
+// result of the same name is declared, the statement assigns that result.
+func (e *expansion) declaredAlongside(obj types.Object) bool {
+	var alongside bool
+	ast.Inspect(e.file, func(n ast.Node) bool {
+		as, ok := n.(*ast.AssignStmt)
+		if !ok || as.Tok != token.DEFINE {
+			return true
+		}
+		declares, others := false, false
+		for _, lhs := range as.Lhs {
+			id, ok := lhs.(*ast.Ident)
+			if !ok || e.info.Defs[id] == nil {
+				continue
+			}
+			if e.info.Defs[id] == obj {
+				declares = true
+			} else {
+				others = true
+			}
+		}
+		if declares {
+			alongside = others
+			return false
+		}
+		return true
+	})
+	return alongside
+}
+
+// nameResults returns an edit naming the results of the caller, using errName
+// for the error result and “_” for all others, e.g. (int, error) → (_ int, err
+// error).
+func (e *expansion) nameResults(errName string) (edit, error) {
+	fields := e.caller.Results.List
+	parts := make([]string, len(fields))
+	for idx, field := range fields {
+		var buf bytes.Buffer
+		if err := format.Node(&buf, e.fset, field.Type); err != nil {
+			return edit{}, err
+		}
+		name := "_"
+		if idx == len(fields)-1 {
+			name = errName
+		}
+		parts[idx] = name + " " + buf.String()
+	}
+	return edit{
+		pos:  e.caller.Results.Pos(),
+		end:  e.caller.Results.End(),
+		text: "(" + strings.Join(parts, ", ") + ")",
+	}, nil
+}
+
+// expandDefer expands the deferred call ds, e.g. defer f.Close() → defer
+// func() { if cerr := f.Close(); cerr != nil && err == nil { err = cerr } }().
+// The caller’s results are named if necessary.
+func (e *expansion) expandDefer(ds *ast.DeferStmt) (ast.Node, []ast.Node, error) {
+	if e.callee.Results().Len() != 1 {
+		return nil, nil, fmt.Errorf("cannot expand deferred %s: it must return only an error", types.ExprString(e.ce.Fun))
+	}
+	if err := e.checkErrorTypes(); err != nil {
+		return nil, nil, err
+	}
+	if _, ok := errorResult(e.callerSig); !ok {
+		return nil, nil, fmt.Errorf("cannot capture the error of deferred %s: the enclosing function does not return an error", types.ExprString(e.ce.Fun))
+	}
+	scope := e.getScope()
+	if scope == nil {
+		return nil, nil, fmt.Errorf("could not find scope")
+	}
+
+	errName := "err"
+	if named := e.namedErrorResult(); named != nil {
+		errName = named.Name()
+	} else {
+		fnScope := e.info.Scopes[e.caller]
+		if obj := fnScope.Lookup(errName); obj != nil {
+			resultErr, _ := errorResult(e.callerSig)
+			if !e.declaredAlongside(obj) || !types.AssignableTo(obj.Type(), resultErr) {
+				// e.g. a parameter named err, or err := f.Sync(), which
+				// would not compile once the error result is named err.
+				// return err still sets the result named differently.
+				fresh := freshName(fnScope, "rerr", fnScope.End(), nil)
+				e.warn("naming the error result %s: %s is already declared at %s",
+					fresh, errName, e.fset.Position(obj.Pos()))
+				errName = fresh
+			}
+		}
+		ed, err := e.nameResults(errName)
+		if err != nil {
+			return nil, nil, err
+		}
+		e.edits = append(e.edits, ed)
+	}
+
+	var body ast.Stmt
+	if e.canJoinErrors() {
+		// e.g. defer f.Close() → defer func() { err = errors.Join(err, f.Close()) }()
+		body = &ast.AssignStmt{
+			Lhs: []ast.Expr{&ast.Ident{Name: errName}},
+			Tok: token.ASSIGN,
+			Rhs: []ast.Expr{&ast.CallExpr{
+				Fun:  e.qualify("errors", "Join"),
+				Args: []ast.Expr{&ast.Ident{Name: errName}, e.ce},
+			}},
+		}
+	} else {
+		cerrName := freshName(scope, "cerr", ds.Pos(), nil)
+		body = &ast.IfStmt{
+			Init: &ast.AssignStmt{
+				Lhs: []ast.Expr{&ast.Ident{Name: cerrName}},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{e.ce},
+			},
+			Cond: &ast.BinaryExpr{
+				X: &ast.BinaryExpr{
+					X:  &ast.Ident{Name: cerrName},
+					Op: token.NEQ,
+					Y:  &ast.Ident{Name: "nil"},
+				},
+				Op: token.LAND,
+				Y: &ast.BinaryExpr{
+					X:  &ast.Ident{Name: errName},
+					Op: token.EQL,
+					Y:  &ast.Ident{Name: "nil"},
+				},
+			},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{&ast.AssignStmt{
+					Lhs: []ast.Expr{&ast.Ident{Name: errName}},
+					Tok: token.ASSIGN,
+					Rhs: []ast.Expr{&ast.Ident{Name: cerrName}},
+				}},
+			},
+		}
+	}
+
+	return ds, []ast.Node{&ast.DeferStmt{
+		Call: &ast.CallExpr{
+			Fun: &ast.FuncLit{
+				Type: &ast.FuncType{Params: &ast.FieldList{}},
+				Body: &ast.BlockStmt{List: []ast.Stmt{body}},
+			},
+		},
+	}}, nil
+}
@@ -51,7 +51,7 @@ func parseQueryPos(fset *token.FileSet, root *ast.File, pos string, needExact bo
 	// Find the named file among those in the loaded program.
 	var file *token.File
 	fset.Iterate(func(f *token.File) bool {
-		if sameFile(filename, f.Name()) {
+		if filename == f.Name() || sameFile(filename, f.Name()) {
 			file = f
 			return false // done
 		}
@@ -61,18 +61,29 @@ func parseQueryPos(fset *token.FileSet, root *ast.File, pos string, needExact bo
 		return nil, fmt.Errorf("file %s not found in loaded program", filename)
 	}
 
-	// decrement startOffset as long as it points to <whitespace>|")", so that PathEnclosingInterval returns an ast.CallExpr
+	// decrement startOffset as long as it points to <whitespace>|")"|"]", so that PathEnclosingInterval returns an ast.CallExpr (or ast.IndexExpr)
 	//log.Printf("filename = %q, startOffset = %d, endOffset = %d\n", filename, startOffset, endOffset)
-	b, err := ioutil.ReadFile(filename)
+	b, err := readFile(filename)
 	if err != nil {
 		return nil, err
 	}
 	//log.Printf("before: %q (rune: %v)", string(b[startOffset-5:endOffset+5]), rune(b[startOffset-1]))
-	for unicode.IsSpace(rune(b[startOffset-1])) || b[startOffset-1] == ')' {
+	if startOffset > len(b) {
+		return nil, errors.New("start position is beyond end of file")
+	}
+	for startOffset > 0 && (unicode.IsSpace(rune(b[startOffset-1])) || b[startOffset-1] == ')' || b[startOffset-1] == ']') {
 		startOffset--
 		endOffset--
 		//log.Printf("decremented to startOffset = %d, endOffset = %d\n", startOffset, endOffset)
 	}
+	// If the cursor is right behind an identifier (e.g. “<-ch”), move it into
+	// the identifier so that its enclosing expression is found.
+	if startOffset > 0 {
+		if c := b[startOffset-1]; c == '_' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)) {
+			startOffset--
+			endOffset--
+		}
+	}
 	//log.Printf("after: %q", string(b[startOffset-5:endOffset+5]))
 
 	start, end, err := fileOffsetToPos(file, startOffset, endOffset)
@@ -94,50 +105,30 @@ func unparen(e ast.Expr) ast.Expr { return astutil.Unparen(e) }
 
 var errUnknownSignature = errors.New("unknown signature")
 
+// signatureOf returns the signature of the function called by e, derived from
+// the type of e.Fun. This works for any kind of callee, e.g. functions, method
+// values and expressions, struct fields, slice elements, or calls returning
+// functions.
 func signatureOf(info *types.Info, e *ast.CallExpr) (*types.Signature, error) {
-	// Deal with obviously static calls before constructing SSA form.
-	// Some static calls may yet require SSA construction,
-	// e.g.  f := func(){}; f().
-	switch funexpr := unparen(e.Fun).(type) {
-	case *ast.Ident:
-		switch obj := info.Uses[funexpr].(type) {
-		case *types.Builtin:
-			// Reject calls to built-ins.
-			return nil, fmt.Errorf("this is a call to the built-in '%s' operator", obj.Name())
-		case *types.Func:
-			// This is a static function call
-			return obj.Type().(*types.Signature), nil
-		case *types.Var:
-			// This is a function literal call
-			return obj.Type().(*types.Signature), nil
-		default:
-			// TODO: better error message: the function signature for <TODO> could not be found
-			//return nil, fmt.Errorf("unhandled: info.Uses[%v] = %T", funexpr, obj)
-			return nil, errUnknownSignature
-		}
-	case *ast.SelectorExpr:
-		sel := info.Selections[funexpr]
-		if sel == nil {
-			// qualified identifier.
-			// May refer to top level function variable
-			// or to top level function.
-			switch callee := info.Uses[funexpr.Sel].(type) {
-			case *types.Func:
-				return callee.Type().(*types.Signature), nil
-			default:
-				// TODO: better error message (see above)
-				return nil, errUnknownSignature
-			}
-		} else if sel.Kind() == types.MethodVal {
-			// Inspect the receiver type of the selected method.
-			// If it is concrete, the call is statically dispatched.
-			// (Due to implicit field selections, it is not enough to look
-			// at sel.Recv(), the type of the actual receiver expression.)
-			method := sel.Obj().(*types.Func)
-			return method.Type().(*types.Signature), nil
-		}
+	fun := unparen(e.Fun)
+	tv, ok := info.Types[fun]
+	if !ok || tv.Type == nil || tv.Type == types.Typ[types.Invalid] {
+		// The callee could not be type-checked, e.g. because it is declared
+		// in another file of the package.
+		return nil, errUnknownSignature
+	}
+	if tv.IsType() {
+		return nil, fmt.Errorf("%s is a conversion to type %s, not a function call", types.ExprString(e), tv.Type)
 	}
-	return nil, fmt.Errorf("unhandled: signature of %T", unparen(e.Fun))
+	if tv.IsBuiltin() {
+		return nil, fmt.Errorf("this is a call to the built-in '%s' operator", types.ExprString(fun))
+	}
+	sig, ok := tv.Type.Underlying().(*types.Signature)
+	if !ok {
+		return nil, fmt.Errorf("cannot call %s: not a function (type %s)", types.ExprString(fun), tv.Type)
+	}
+	// For generic functions, tv.Type is the instantiated signature.
+	return sig, nil
 }
 
 func currentSignature(path []ast.Node) (*ast.FuncType, error) {
@@ -152,71 +143,59 @@ func currentSignature(path []ast.Node) (*ast.FuncType, error) {
 	return nil, fmt.Errorf("no function definition found in path")
 }
 
-// newZeroValueNode returns an AST expr representing the zero value of
-// typ. If determining the zero value requires additional information
-// (e.g., type-checking output), it returns nil.
-// (from github.com/sqs/goreturns/returns/fix.go)
-func newZeroValueNode(typ ast.Expr) ast.Expr {
-	switch v := typ.(type) {
-	case *ast.Ident:
-		switch v.Name {
-		case "uint8", "uint16", "uint32", "uint64", "int8", "int16", "int32", "int64", "byte", "rune", "uint", "int", "uintptr":
-			return &ast.BasicLit{Kind: token.INT, Value: "0"}
-		case "float32", "float64":
-			return &ast.BasicLit{Kind: token.FLOAT, Value: "0"}
-		case "complex64", "complex128":
-			return &ast.BasicLit{Kind: token.IMAG, Value: "0"}
-		case "bool":
+// zeroValue returns an AST expr representing the zero value of typ, or nil if
+// typ is invalid (e.g. due to type-checking errors). Types of other packages
+// are referred to by the names under which the file under cursor imports them.
+func (e *expansion) zeroValue(typ types.Type) ast.Expr {
+	if tp, ok := types.Unalias(typ).(*types.TypeParam); ok {
+		// The zero value of a type parameter cannot be expressed as a literal.
+		return &ast.StarExpr{X: &ast.CallExpr{
+			Fun:  &ast.Ident{Name: "new"},
+			Args: []ast.Expr{&ast.Ident{Name: tp.Obj().Name()}},
+		}}
+	}
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsBoolean != 0:
 			return &ast.Ident{Name: "false"}
-		case "string":
+		case t.Info()&types.IsString != 0:
 			return &ast.BasicLit{Kind: token.STRING, Value: `""`}
-		case "error":
-			return &ast.Ident{Name: "nil"}
-		}
-	case *ast.ArrayType:
-		if v.Len == nil {
-			// slice
+		case t.Info()&types.IsNumeric != 0:
+			return &ast.BasicLit{Kind: token.INT, Value: "0"}
+		case t.Kind() == types.UnsafePointer:
 			return &ast.Ident{Name: "nil"}
 		}
-		return &ast.CompositeLit{Type: v}
-	case *ast.StarExpr:
+	case *types.Pointer, *types.Slice, *types.Map, *types.Chan, *types.Signature, *types.Interface:
 		return &ast.Ident{Name: "nil"}
+	case *types.Struct, *types.Array:
+		typeExpr, err := parser.ParseExpr(types.TypeString(typ, e.qualifier))
+		if err != nil {
+			return nil
+		}
+		return &ast.CompositeLit{Type: typeExpr}
 	}
 	return nil
 }
 
-// Like newZeroValueNode, but with type information.
-//
-// TODO: can we safely get rid of newZeroValueNode or does it handle cases which
-// would otherwise go unhandled?
-func newZeroValueNodeTypeName(id *ast.Ident, name *types.TypeName) ast.Expr {
-	switch t := name.Type().Underlying().(type) {
-	case *types.Struct:
-		return &ast.Ident{Name: id.Name + "{}"}
-
-	case *types.Interface:
-		return &ast.Ident{Name: "nil"}
-
-	case *types.Basic:
-		switch t.Kind() {
-		case types.Int, types.Int8, types.Int16, types.Int32, types.Int64,
-			types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64, types.Uintptr:
-			return &ast.BasicLit{Kind: token.INT, Value: "0"}
-
-		case types.Float32, types.Float64:
-			return &ast.BasicLit{Kind: token.FLOAT, Value: "0"}
-
-		case types.Complex64, types.Complex128:
-			return &ast.BasicLit{Kind: token.IMAG, Value: "0"}
-
-		case types.Bool:
-			return &ast.Ident{Name: "false"}
-
-		case types.String:
-			return &ast.BasicLit{Kind: token.STRING, Value: `""`}
+// callerSignature returns the type-checked signature of the function
+// enclosing the cursor.
+func (e *expansion) callerSignature() (*types.Signature, error) {
+	for _, n := range e.path {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if obj, ok := e.info.Defs[n.Name].(*types.Func); ok {
+				return obj.Type().(*types.Signature), nil
+			}
+			return nil, errUnknownSignature
+		case *ast.FuncLit:
+			if sig, ok := e.info.TypeOf(n).(*types.Signature); ok {
+				return sig, nil
+			}
+			return nil, errUnknownSignature
 		}
 	}
-	return nil
+	return nil, fmt.Errorf("no function definition found in path")
 }
 
 // fallbackImporter tries to import using importer first, falling back to
@@ -245,10 +224,15 @@ func (fi *fallbackImporter) ImportFrom(path, srcDir string, mode types.ImportMod
 
 func callExprAtPath(path []ast.Node) *ast.CallExpr {
 	var ce *ast.CallExpr
-	// Return the outer-most *ast.CallExpr in path, if any.
+	// Return the outer-most *ast.CallExpr in path within the enclosing
+	// function, if any.
+loop:
 	for _, p := range path {
-		if e, ok := p.(*ast.CallExpr); ok {
-			ce = e
+		switch p := p.(type) {
+		case *ast.CallExpr:
+			ce = p
+		case *ast.FuncLit:
+			break loop
 		}
 	}
 	if ce != nil {
@@ -293,15 +277,26 @@ func defaultImporter() types.Importer {
 
 // expansion holds state during the error expansion.
 type expansion struct {
-	fset    *token.FileSet
-	file    *ast.File        // the file under cursor
-	ce      *ast.CallExpr    // the call expression under the cursor
-	callee  *types.Signature // the callee’s signature
-	caller  *ast.FuncType    // the caller’s type (including signature)
-	results []ast.Expr       // return values for the new error check
-	info    *types.Info      // type information of the type-checked package
-	pkg     *types.Package
-	path    []ast.Node // node under cursor and all its ancestors
+	fset      *token.FileSet
+	file      *ast.File          // the file under cursor
+	ce        *ast.CallExpr      // the call expression under the cursor
+	commaOk   ast.Expr           // the comma-ok expression under the cursor, if any
+	chain     []*ast.CallExpr    // the method chain ending in ce, if it needs to be split
+	chainSigs []*types.Signature // the signatures of the calls in chain
+	callee    *types.Signature   // the callee’s signature
+	caller    *ast.FuncType      // the caller’s type (including signature)
+	callerSig *types.Signature   // the caller’s type-checked signature
+	results   []ast.Expr         // return values for the new error check
+	info      *types.Info        // type information of the type-checked package
+	pkg       *types.Package
+	path      []ast.Node     // node under cursor and all its ancestors
+	src       []byte         // the contents of the file under cursor
+	importer  types.Importer // imports of the package under cursor, if loaded using go/packages
+	pkgFiles  []string       // files of the package under cursor, if loaded using go/packages
+	cursor    token.Pos      // the cursor position, not counting whitespace
+	imports   []string       // import paths which need to be added to file
+	edits     []edit         // replacements outside of the subject, in order
+	warnings  []string       // printed along with the expansion
 }
 
 func (e *expansion) getScope() *types.Scope {
@@ -318,13 +313,146 @@ func (e *expansion) getScope() *types.Scope {
 	return nil
 }
 
-func errPresent(lhs []ast.Expr) bool {
-	for _, expr := range lhs {
-		if id, ok := expr.(*ast.Ident); ok && id.Name == "err" {
+// errorSlot returns the left-hand expression of an assignment of e.ce which
+// receives the callee’s error result, or nil if the error is not assigned,
+// e.g. in f := os.Create(…). lhs is matched to the callee’s results by
+// position, so an error is returned if their numbers do not match.
+func (e *expansion) errorSlot(lhs []ast.Expr) (ast.Expr, error) {
+	results := e.callee.Results()
+	switch len(lhs) {
+	case results.Len():
+		return lhs[len(lhs)-1], nil
+	case results.Len() - 1:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("assignment mismatch: %d variable(s) but %s returns %d value(s), expected %d or %d variable(s)",
+		len(lhs), types.ExprString(e.ce), results.Len(), results.Len()-1, results.Len())
+}
+
+// errorSlotName returns the name of the variable to which the error is
+// assigned in the error slot (see errorSlot) and whether it must be assigned
+// (“=”). A discarded error (“_”) is upgraded to a new error variable.
+func (e *expansion) errorSlotName(scope *types.Scope, subject ast.Node, slot ast.Expr, scoped bool) (string, bool, error) {
+	id, ok := slot.(*ast.Ident)
+	if slot != nil && !ok {
+		return "", false, fmt.Errorf("cannot check the error assigned to %s: assign it to a variable", types.ExprString(slot))
+	}
+	if slot == nil || id.Name == "_" {
+		name, assign := e.errVar(scope, subject, scoped)
+		return name, assign, nil
+	}
+	// Keep the name chosen by the user, e.g. werr in n, werr := w.Write(b).
+	named := e.namedErrorResult()
+	return id.Name, named != nil && named.Name() == id.Name, nil
+}
+
+// resultNames returns names for all but the last (error) result of results,
+// to be used when assigning the results of stmt, the bare call statement. The
+// result parameter names are used if present, otherwise names are
+// derived from the result types. Results which are not referred to after stmt
+// are discarded using “_”.
+func (e *expansion) resultNames(scope *types.Scope, stmt ast.Stmt, results *types.Tuple) []ast.Expr {
+	used := e.unresolvedAfter(stmt)
+	taken := make(map[string]bool)
+	names := make([]ast.Expr, results.Len()-1)
+	for idx := range names {
+		name := results.At(idx).Name()
+		if name == "" || name == "_" {
+			name = nameForType(results.At(idx).Type())
+		}
+		// Avoid collisions with variables in scope and other results.
+		name = freshName(scope, name, stmt.Pos(), taken)
+		if !used[name] {
+			names[idx] = &ast.Ident{Name: "_"}
+			continue
+		}
+		taken[name] = true
+		names[idx] = &ast.Ident{Name: name}
+	}
+	return names
+}
+
+// freshName returns name, or name followed by a number if name is taken or
+// already in scope at pos, e.g. “f2”.
+func freshName(scope *types.Scope, name string, pos token.Pos, taken map[string]bool) string {
+	base := name
+	for i := 2; taken[name] || lookup(scope, name, pos) != nil; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	return name
+}
+
+// lookup returns the object named name which is in scope at pos, if any.
+func lookup(scope *types.Scope, name string, pos token.Pos) types.Object {
+	_, obj := scope.LookupParent(name, pos)
+	return obj
+}
+
+// unresolvedAfter returns the names of all identifiers which are referred to
+// after stmt within its enclosing block, but which are not declared.
+func (e *expansion) unresolvedAfter(stmt ast.Stmt) map[string]bool {
+	names := make(map[string]bool)
+	block, ok := e.parent(stmt).(*ast.BlockStmt)
+	if !ok {
+		return names
+	}
+	for _, s := range block.List {
+		if s.Pos() <= stmt.Pos() {
+			continue
+		}
+		ast.Inspect(s, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				ast.Inspect(sel.X, func(n ast.Node) bool {
+					if id, ok := n.(*ast.Ident); ok && e.info.Uses[id] == nil && e.info.Defs[id] == nil {
+						names[id.Name] = true
+					}
+					return true
+				})
+				return false // do not consider field and method names
+			}
+			if id, ok := n.(*ast.Ident); ok && e.info.Uses[id] == nil && e.info.Defs[id] == nil {
+				names[id.Name] = true
+			}
 			return true
+		})
+	}
+	return names
+}
+
+// nameForType returns a short variable name for a value of type typ, e.g. “f”
+// for *os.File or “n” for int.
+func nameForType(typ types.Type) string {
+	typ = types.Unalias(typ)
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = types.Unalias(ptr.Elem())
+	}
+	switch t := typ.(type) {
+	case *types.Named:
+		return strings.ToLower(string([]rune(t.Obj().Name())[:1]))
+	case *types.TypeParam:
+		return strings.ToLower(string([]rune(t.Obj().Name())[:1]))
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsInteger != 0:
+			return "n"
+		case t.Info()&types.IsString != 0:
+			return "s"
+		case t.Info()&types.IsBoolean != 0:
+			return "ok"
+		}
+	case *types.Slice:
+		if elem, ok := t.Elem().(*types.Basic); ok && elem.Kind() == types.Byte {
+			return "b"
 		}
+		return "s"
+	case *types.Map:
+		return "m"
+	case *types.Chan:
+		return "ch"
+	case *types.Signature:
+		return "fn"
 	}
-	return false
+	return "v"
 }
 
 // parent returns the parent node of n within e.path.
@@ -341,13 +469,19 @@ func (e *expansion) parent(n ast.Node) ast.Node {
 
 func (e *expansion) typeCheck(pkgname string, files []*ast.File, addWarnFunc func(string)) error {
 	e.info = &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
 		Uses:       make(map[*ast.Ident]types.Object),
 		Selections: make(map[*ast.SelectorExpr]*types.Selection),
 		Scopes:     make(map[ast.Node]*types.Scope),
 	}
 
+	imp := e.importer
+	if imp == nil {
+		imp = defaultImporter()
+	}
 	conf := types.Config{
-		Importer: defaultImporter(),
+		Importer: imp,
 		Error: func(err error) {
 			addWarnFunc(fmt.Sprintf("ignoring type-checking error: %v", err))
 		}, // keep going on errors
@@ -364,145 +498,246 @@ func (e *expansion) typeCheck(pkgname string, files []*ast.File, addWarnFunc fun
 		return err
 	}
 
-	e.ce = callExprAtPath(e.path)
-	if e.ce == nil {
-		return fmt.Errorf("no ast.CallExpr found")
+	e.commaOk = e.commaOkExprAtPath()
+	if e.commaOk == nil {
+		e.ce = callExprAtPath(e.path)
+		if e.ce == nil {
+			return errors.New("no ast.CallExpr found")
+		}
+		inner, err := e.innerCallAtCursor()
+		if err != nil {
+			return err
+		}
+		if inner != nil {
+			e.ce = inner
+		}
+		e.chain, e.chainSigs, err = e.methodChain(e.ce)
+		if err != nil {
+			return err
+		}
+		if e.chain != nil {
+			e.callee = e.chainSigs[len(e.chainSigs)-1]
+		} else {
+			e.callee, err = signatureOf(e.info, e.ce)
+			if err != nil {
+				return err
+			}
+		}
 	}
-	e.callee, err = signatureOf(e.info, e.ce)
+
+	e.callerSig, err = e.callerSignature()
 	if err != nil {
 		return err
 	}
 
-	if e.caller.Results == nil {
-		return nil
-	}
-
-	e.results = make([]ast.Expr, len(e.caller.Results.List))
-	for idx, res := range e.caller.Results.List {
-		if id, ok := res.Type.(*ast.Ident); ok && id.Name == "error" {
+	results := e.callerSig.Results()
+	_, returnsError := errorResult(e.callerSig)
+	e.results = make([]ast.Expr, results.Len())
+	for idx := 0; idx < results.Len(); idx++ {
+		typ := results.At(idx).Type()
+		if returnsError && idx == results.Len()-1 {
 			e.results[idx] = &ast.Ident{Name: "err"}
-		} else {
-			e.results[idx] = newZeroValueNode(res.Type)
-			if e.results[idx] == nil {
-				// We could not figure out from the AST what the type is, so
-				// it’s not a builtin type, array type or pointer type.
-				if id, ok := res.Type.(*ast.Ident); ok {
-					if tn, ok := e.info.Uses[id].(*types.TypeName); ok {
-						e.results[idx] = newZeroValueNodeTypeName(id, tn)
-					}
+			continue
+		}
+		if _, ok := types.Unalias(typ).(*types.TypeParam); ok {
+			// Prefer a “var zero T” declared by the caller over *new(T).
+			if scope := e.getScope(); scope != nil {
+				if obj := lookup(scope, "zero", e.path[0].Pos()); obj != nil && types.Identical(obj.Type(), typ) {
+					e.results[idx] = &ast.Ident{Name: "zero"}
+					continue
 				}
 			}
 		}
+		e.results[idx] = e.zeroValue(typ)
+		if e.results[idx] == nil {
+			// The result type could not be determined, e.g. because it is
+			// declared in another file of the package.
+			return errUnknownSignature
+		}
 	}
 	return nil
 }
 
-// this function either returns just the return values of the function or, if there are no errors
-// returned, will also add in the no-error-callback
-func (e *expansion) getFinalOutput(noReturnStr string, errName string) ([]ast.Stmt, error) {
-	var normalReturn = &ast.ReturnStmt{Results: e.results}
+var errorInterface = types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
 
-	var returnsError = true
-	if res := e.caller.Results; res == nil {
-		returnsError = false
-	} else if list := res.List; len(list) == 0 {
-		returnsError = false
-	} else if id, ok := list[len(list)-1].Type.(*ast.Ident); ok && id.Name != "error" {
-		returnsError = false
+// isError returns whether typ implements the error interface, e.g. error
+// itself, a concrete error type or an interface embedding error.
+func isError(typ types.Type) bool {
+	if typ == nil || typ == types.Typ[types.Invalid] {
+		return false
 	}
+	return types.Implements(typ, errorInterface)
+}
 
-	if returnsError {
-		return []ast.Stmt{normalReturn}, nil
+// errorResult returns the type of the last result of sig if it implements the
+// error interface.
+func errorResult(sig *types.Signature) (types.Type, bool) {
+	results := sig.Results()
+	if results.Len() == 0 {
+		return nil, false
 	}
+	typ := results.At(results.Len() - 1).Type()
+	return typ, isError(typ)
+}
 
-	if noReturnStr == "" { // default output when no error retuned
-		return []ast.Stmt{&ast.ExprStmt{
-			X: &ast.CallExpr{
-				Fun: &ast.Ident{Name: "panic"},
-				Args: []ast.Expr{
-					&ast.Ident{Name: "err"},
-				},
-			},
-		}}, nil
+// checkErrorTypes verifies that the callee returns an error and warns about
+// error types which need attention when propagating the error.
+func (e *expansion) checkErrorTypes() error {
+	calleeErr, ok := errorResult(e.callee)
+	if !ok {
+		return fmt.Errorf("%s does not return an error (last result has type %s)",
+			types.ExprString(e.ce.Fun), types.TypeString(calleeErr, e.messageQualifier))
+	}
+	callerErr, ok := errorResult(e.callerSig)
+	if !ok {
+		return nil
 	}
+	if !types.IsInterface(callerErr) {
+		e.warn("the enclosing function returns the concrete error type %s: a nil %s returned as error is not equal to nil (typed nil pitfall), consider returning error instead",
+			types.TypeString(callerErr, e.messageQualifier), types.TypeString(callerErr, e.messageQualifier))
+	}
+	if !types.AssignableTo(calleeErr, callerErr) {
+		e.warn("%s returns an error of type %s, which is not assignable to the result type %s",
+			types.ExprString(e.ce.Fun), types.TypeString(calleeErr, e.messageQualifier), types.TypeString(callerErr, e.messageQualifier))
+	}
+	return nil
+}
 
-	noReturnExpr, err := parser.ParseExpr(noReturnStr)
-	if err != nil {
-		return nil, fmt.Errorf("parsing template result into ast: %v", err)
+// namedErrorResult returns the caller’s error result if it is named (e.g.
+// “err” in “func load() (cfg *Config, err error)”), nil otherwise.
+func (e *expansion) namedErrorResult() *types.Var {
+	if _, ok := errorResult(e.callerSig); !ok {
+		return nil
 	}
+	results := e.callerSig.Results()
+	v := results.At(results.Len() - 1)
+	if v.Name() == "" || v.Name() == "_" {
+		return nil
+	}
+	return v
+}
 
-	return []ast.Stmt{
-		&ast.ExprStmt{X: noReturnExpr},
-		normalReturn,
-	}, nil
+// warn adds a warning which is printed along with the expansion.
+func (e *expansion) warn(format string, args ...interface{}) {
+	e.warnings = append(e.warnings, fmt.Sprintf(format, args...))
 }
 
-func logic(w io.Writer, buildctx *build.Context, posn, noReturnStr string) error {
-	e := expansion{
-		fset: token.NewFileSet(),
+// this function either returns just the return values of the function or, if there are no errors
+// returned, will also add in the no-error-callback
+func (e *expansion) getFinalOutput(noReturnStr string, errName string) ([]ast.Stmt, error) {
+	var errExpr ast.Expr = &ast.Ident{Name: errName}
+	if *wrapFlag {
+		errExpr = e.wrapErr(errExpr)
 	}
+	return e.failureOutput(noReturnStr, errName, errExpr)
+}
 
-	// Short-cut: parse+type-check a single file before loading the entire
-	// package.
-	filename, _, _, err := parsePos(posn)
-	if err != nil {
-		return err
+// failureOutput returns the statements which propagate or otherwise handle
+// the error errExpr. errName is the name of the error variable, if any.
+func (e *expansion) failureOutput(noReturnStr string, errName string, errExpr ast.Expr) ([]ast.Stmt, error) {
+	results := make([]ast.Expr, len(e.results))
+	for idx, res := range e.results {
+		if id, ok := res.(*ast.Ident); ok && id.Name == "err" {
+			res = errExpr
+		}
+		results[idx] = res
+	}
+	var normalReturn = &ast.ReturnStmt{Results: results}
+
+	if _, returnsError := errorResult(e.callerSig); returnsError {
+		if *bareReturn && e.namedErrorResult() != nil {
+			// Deferred error handlers observe the named error result, so
+			// assign any wrapped error before returning.
+			if id, ok := errExpr.(*ast.Ident); !ok || id.Name != errName {
+				return []ast.Stmt{
+					&ast.AssignStmt{
+						Lhs: []ast.Expr{&ast.Ident{Name: errName}},
+						Tok: token.ASSIGN,
+						Rhs: []ast.Expr{errExpr},
+					},
+					&ast.ReturnStmt{},
+				}, nil
+			}
+			return []ast.Stmt{&ast.ReturnStmt{}}, nil
+		}
+		return []ast.Stmt{normalReturn}, nil
 	}
 
-	e.file, err = parser.ParseFile(e.fset, filename, nil, parser.ParseComments)
-	if err != nil {
-		return fmt.Errorf("parsing: %v", err)
+	// An explicitly specified callback takes precedence over the handling
+	// derived from the context below.
+	if noReturnStr != "" {
+		return callbackOutput(noReturnStr, errExpr, normalReturn)
 	}
 
-	e.path, err = parseQueryPos(e.fset, e.file, posn, false)
-	if err != nil {
-		return err
+	if stmts := e.goroutineOutput(errExpr); stmts != nil {
+		return stmts, nil
+	}
+	if tb := e.testingVar(); tb != nil {
+		return e.testOutput(tb, errExpr), nil
+	}
+	if w := e.responseWriter(); w != nil {
+		return []ast.Stmt{e.httpError(w, errExpr), normalReturn}, nil
+	}
+	if gs, _ := e.goStmt(); gs != nil {
+		e.warn("the error is not handled within the goroutine: consider sending it on a chan error or using an errgroup.Group")
 	}
 
-	// TODO(golang.org/issues/21418): hack: importer.For always uses
-	// build.Default, so we need to change build.Default
-	build.Default = *buildctx
+	// Neither main nor init can return errors, so terminate the program.
+	if e.file.Name.Name == "main" && e.enclosingFunc() == "main" {
+		return e.mainOutput(errExpr), nil
+	}
+	if e.enclosingFunc() == "init" {
+		return e.initOutput(errExpr), nil
+	}
 
-	var warnings []string
-	var warnFunc = func(warning string) { warnings = append(warnings, warning) }
-	if err := e.typeCheck("main", []*ast.File{e.file}, warnFunc); err != nil {
-		if err != errUnknownSignature {
-			return err
-		}
+	// default output when no error retuned
+	return []ast.Stmt{&ast.ExprStmt{
+		X: &ast.CallExpr{
+			Fun: &ast.Ident{Name: "panic"},
+			Args: []ast.Expr{
+				errExpr,
+			},
+		},
+	}}, nil
+}
 
-		// Parse all files, type-check again.
-		d, err := os.Open(filepath.Dir(filename))
-		if err != nil {
-			return err
-		}
-		defer d.Close()
-		names, err := d.Readdirnames(-1)
-		if err != nil {
-			return err
-		}
-		files := []*ast.File{e.file}
-		// TODO: parallelize
-		for _, n := range names {
-			if n == filepath.Base(filename) {
-				continue // already parsed
-			}
-			if strings.HasPrefix(n, "expanderr") {
-				continue // skip expanderr temp file when working in /tmp
-			}
-			if !strings.HasSuffix(n, ".go") {
-				continue
-			}
-			f, err := parser.ParseFile(e.fset, filepath.Join(filepath.Dir(filename), n), nil, parser.ParseComments)
-			if err != nil {
-				return fmt.Errorf("parsing: %v", err)
+// callbackOutput returns the statements calling noReturnStr, a template
+// referring to the error as “err”, followed by normalReturn.
+func callbackOutput(noReturnStr string, errExpr ast.Expr, normalReturn *ast.ReturnStmt) ([]ast.Stmt, error) {
+	noReturnExpr, err := parser.ParseExpr(noReturnStr)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template result into ast: %v", err)
+	}
+	if id, ok := errExpr.(*ast.Ident); !ok || id.Name != "err" {
+		// The template refers to the error as “err”.
+		noReturnExpr = astutil.Apply(noReturnExpr, func(c *astutil.Cursor) bool {
+			if id, ok := c.Node().(*ast.Ident); ok && id.Name == "err" {
+				if _, ok := c.Parent().(*ast.SelectorExpr); !ok || c.Name() == "X" {
+					c.Replace(errExpr)
+				}
 			}
-			files = append(files, f)
-		}
-		if err := e.typeCheck(e.pkg.Name(), files, warnFunc); err != nil {
-			return err
+			return true
+		}, nil).(ast.Expr)
+	}
+
+	return []ast.Stmt{
+		&ast.ExprStmt{X: noReturnExpr},
+		normalReturn,
+	}, nil
+}
+
+// expandCall expands e.ce, returning the node to be replaced and its
+// replacement.
+func (e *expansion) expandCall(noReturnStr string) (ast.Node, []ast.Node, error) {
+	if e.callee.Results().Len() > 0 {
+		if err := e.checkErrorTypes(); err != nil {
+			return nil, nil, err
 		}
 	}
 
+	errName := "err"
+	scope := e.getScope()
+
 	var subject ast.Node // what will be replaced
 	subject = e.ce
 	var repl []ast.Node
@@ -511,27 +746,62 @@ func logic(w io.Writer, buildctx *build.Context, posn, noReturnStr string) error
 		// nothing to replace, i.e. keep the original *ast.CallExpr
 		repl = []ast.Node{e.ce}
 	case 1:
-		// TODO: check if this CallExpr is within an AssignStmt. if so, replace the AssignStmt instead
-		if parent := e.parent(subject); parent != nil {
-			if stmt, ok := parent.(*ast.AssignStmt); ok {
-				subject = stmt
+		// e.g. werr := w.Flush() → if werr := w.Flush(); werr != nil { … }
+		var slot ast.Expr
+		if stmt, ok := e.parent(subject).(*ast.AssignStmt); ok {
+			subject = stmt
+			var err error
+			if slot, err = e.errorSlot(stmt.Lhs); err != nil {
+				return nil, nil, err
 			}
 		}
 
-		outputStmt, err := e.getFinalOutput(noReturnStr, "err")
+		// Assign to a named error result (or an err inspected by a deferred
+		// function) instead of shadowing it.
+		var assign bool
+		var err error
+		errName, assign, err = e.errorSlotName(scope, subject, slot, true)
 		if err != nil {
-			return err
+			return nil, nil, err
+		}
+		tok := token.DEFINE
+		if id, ok := slot.(*ast.Ident); assign || (ok && id.Name != "_" && subject.(*ast.AssignStmt).Tok == token.ASSIGN) {
+			tok = token.ASSIGN
+		}
+
+		outputStmt, err := e.getFinalOutput(noReturnStr, errName)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if id, ok := slot.(*ast.Ident); ok && id.Name != "_" && scope != nil {
+			if obj := e.info.ObjectOf(id); obj != nil && e.usedWithin(obj, subject.End(), scope.End()) {
+				// The error variable is referred to afterwards, so it must
+				// not be scoped to the error check, e.g. err := f.Sync();
+				// return err → err := f.Sync(); if err != nil { … }; return err
+				repl = []ast.Node{subject, &ast.IfStmt{
+					Cond: &ast.BinaryExpr{
+						X:  &ast.Ident{Name: errName},
+						Op: token.NEQ,
+						Y:  &ast.Ident{Name: "nil"},
+					},
+					Body: &ast.BlockStmt{
+						List: outputStmt,
+					},
+				}}
+				break
+			}
 		}
 
 		// e.g. os.Remove(…) → if err := os.Remove(…); err != nil { return 0, err }
 		repl = []ast.Node{&ast.IfStmt{
 			Init: &ast.AssignStmt{
-				Lhs: []ast.Expr{&ast.Ident{Name: "err"}},
-				Tok: token.DEFINE,
+				Lhs: []ast.Expr{&ast.Ident{Name: errName}},
+				Tok: tok,
 				Rhs: []ast.Expr{e.ce},
 			},
 			Cond: &ast.BinaryExpr{
-				X:  &ast.Ident{Name: "err"},
+				X:  &ast.Ident{Name: errName},
 				Op: token.NEQ,
 				Y:  &ast.Ident{Name: "nil"},
 			},
@@ -542,25 +812,39 @@ func logic(w io.Writer, buildctx *build.Context, posn, noReturnStr string) error
 	default:
 		// e.g. f := os.Create(…) → f, err := os.Create(…); if err != nil { return 0, err }
 
-		// walk up to the *ast.AssignStmt and append an *ast.Ident to its .Lhs
-		var as *ast.AssignStmt
-		for _, p := range e.path {
-			if p, ok := p.(*ast.AssignStmt); ok {
-				as = p
-				break
-			}
-		}
-		if as == nil {
-			return fmt.Errorf("no *ast.AssignStmt found in path") // TODO: better error msg
-		}
-
-		scope := e.getScope()
+		// append an *ast.Ident to the .Lhs of the *ast.AssignStmt assigning
+		// the results of e.ce, if any (but not to an *ast.AssignStmt further
+		// up, e.g. one assigning a function literal containing e.ce)
+		as, _ := e.parent(e.ce).(*ast.AssignStmt)
 		if scope == nil {
-			return fmt.Errorf("could not find scope") // TODO: better error msg. can this happen at all?
+			return nil, nil, fmt.Errorf("could not find scope") // TODO: better error msg. can this happen at all?
 		}
-		errInScope := scope.Lookup("err") != nil
 
 		subject = as
+		if as == nil {
+			// e.g. os.Create(…) → f, err := os.Create(…)
+			stmt, ok := e.parent(e.ce).(*ast.ExprStmt)
+			if !ok {
+				return nil, nil, fmt.Errorf("cannot expand %s: its results are neither assigned nor is it a statement", types.ExprString(e.ce))
+			}
+			as = &ast.AssignStmt{
+				Lhs: e.resultNames(scope, stmt, e.callee.Results()),
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{e.ce},
+			}
+			subject = stmt
+		}
+		slot, err := e.errorSlot(as.Lhs)
+		if err != nil {
+			return nil, nil, err
+		}
+		var assign bool
+		errName, assign, err = e.errorSlotName(scope, subject, slot, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		obj := lookup(scope, errName, subject.Pos())
+		errInScope := obj != nil && obj.Parent() == scope
 
 		onlyUnderscore := true
 		for _, lhs := range as.Lhs {
@@ -574,24 +858,74 @@ func logic(w io.Writer, buildctx *build.Context, posn, noReturnStr string) error
 			}
 		}
 
-		// TODO: verify all other parameters are assigned
+		if slot == nil {
+			as.Lhs = append(as.Lhs, &ast.Ident{Name: errName})
+		} else {
+			// e.g. n, _ := io.Copy(…) → n, err := io.Copy(…)
+			as.Lhs = append(as.Lhs[:len(as.Lhs)-1:len(as.Lhs)-1], &ast.Ident{Name: errName})
+		}
 
-		if !errPresent(as.Lhs) {
-			as.Lhs = append(as.Lhs, &ast.Ident{Name: "err"})
+		if as.Tok == token.DEFINE && !onlyUnderscore && declaredIn(scope, as.Lhs, subject.Pos()) {
+			e.warn("assigning using “=”: all variables on the left-hand side are already declared")
+			as.Tok = token.ASSIGN
 		}
 
-		outputStmt, err := e.getFinalOutput(noReturnStr, "err")
+		outputStmt, err := e.getFinalOutput(noReturnStr, errName)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 
-		if !onlyUnderscore && as.Tok == token.DEFINE {
+		if assign && !onlyUnderscore && as.Tok == token.DEFINE && !errInScope {
+			// “:=” would declare a new variable shadowing the named error
+			// result (or the err inspected by a deferred function), so
+			// declare the other variables and assign instead.
+			for idx, lhs := range as.Lhs {
+				id, ok := lhs.(*ast.Ident)
+				if !ok || id.Name == "_" || id.Name == errName {
+					continue
+				}
+				if obj := scope.Lookup(id.Name); obj != nil && obj.Pos() != id.Pos() {
+					continue // declared before, not by this statement
+				}
+				if idx >= e.callee.Results().Len() {
+					break
+				}
+				typeExpr, err := parser.ParseExpr(types.TypeString(e.callee.Results().At(idx).Type(), e.qualifier))
+				if err != nil {
+					return nil, nil, err
+				}
+				repl = append(repl, &ast.DeclStmt{
+					Decl: &ast.GenDecl{
+						Tok: token.VAR,
+						Specs: []ast.Spec{
+							&ast.ValueSpec{
+								Names: []*ast.Ident{{Name: id.Name}},
+								Type:  typeExpr,
+							},
+						},
+					},
+				})
+			}
+			as.Tok = token.ASSIGN
+			repl = append(repl,
+				as,
+				&ast.IfStmt{
+					Cond: &ast.BinaryExpr{
+						X:  &ast.Ident{Name: errName},
+						Op: token.NEQ,
+						Y:  &ast.Ident{Name: "nil"},
+					},
+					Body: &ast.BlockStmt{
+						List: outputStmt,
+					},
+				})
+		} else if !onlyUnderscore && as.Tok == token.DEFINE {
 			// Insert a new *ast.IfStmt after the *ast.CallExpr.
 			repl = []ast.Node{
-				subject,
+				as,
 				&ast.IfStmt{
 					Cond: &ast.BinaryExpr{
-						X:  &ast.Ident{Name: "err"},
+						X:  &ast.Ident{Name: errName},
 						Op: token.NEQ,
 						Y:  &ast.Ident{Name: "nil"},
 					},
@@ -605,20 +939,32 @@ func logic(w io.Writer, buildctx *build.Context, posn, noReturnStr string) error
 			if onlyUnderscore {
 				tok = token.DEFINE
 			}
-			if !onlyUnderscore && !errInScope {
+			calleeErr, _ := errorResult(e.callee)
+			if !onlyUnderscore && !errInScope && !assign {
 				// The “err” identifier is not yet in scope, so insert a “var
-				// err error” declaration before the *ast.IfStmt.
+				// err error” declaration before the *ast.IfStmt. Concrete
+				// error types are declared as such: storing a nil pointer in
+				// an error interface would make “err != nil” always true.
+				var errType ast.Expr = &ast.Ident{Name: "error"}
+				if !types.IsInterface(calleeErr) {
+					if expr, err := parser.ParseExpr(types.TypeString(calleeErr, e.qualifier)); err == nil {
+						errType = expr
+					}
+				}
 				repl = append(repl, &ast.DeclStmt{
 					Decl: &ast.GenDecl{
 						Tok: token.VAR,
 						Specs: []ast.Spec{
 							&ast.ValueSpec{
-								Names: []*ast.Ident{&ast.Ident{Name: "err"}},
-								Type:  &ast.Ident{Name: "error"},
+								Names: []*ast.Ident{&ast.Ident{Name: errName}},
+								Type:  errType,
 							},
 						},
 					},
 				})
+			} else if !onlyUnderscore && !types.IsInterface(calleeErr) {
+				e.warn("assigning %s to %s: a nil %s stored in an error interface is not equal to nil (typed nil pitfall)",
+					types.TypeString(calleeErr, e.messageQualifier), errName, types.TypeString(calleeErr, e.messageQualifier))
 			}
 			// Embed the *ast.CallExpr in an *ast.IfStmt.
 			repl = append(repl, &ast.IfStmt{
@@ -628,32 +974,211 @@ func logic(w io.Writer, buildctx *build.Context, posn, noReturnStr string) error
 					Rhs: []ast.Expr{e.ce},
 				},
 				Cond: &ast.BinaryExpr{
-					X:  &ast.Ident{Name: "err"},
+					X:  &ast.Ident{Name: errName},
 					Op: token.NEQ,
 					Y:  &ast.Ident{Name: "nil"},
 				},
 				Body: &ast.BlockStmt{
-					List: []ast.Stmt{
-						&ast.ReturnStmt{Results: e.results},
-					},
+					List: outputStmt,
 				},
 			})
 		}
 	}
 
-	// TODO(golang.org/issues/20744): switch from textual replacement to
-	// formatting the AST once comments are represented in a more convenient
-	// way.
+	repl = e.requireNoError(errName, repl)
+	if hdr, init := e.headerOf(subject); hdr != nil && e.callee.Results().Len() > 0 {
+		return e.expandHeader(hdr, init, repl)
+	}
+	return subject, repl, nil
+}
+
+// edit is a textual replacement outside of the replaced statement, e.g. naming
+// the results of the enclosing function.
+type edit struct {
+	pos, end token.Pos
+	text     string
+}
+
+// writeEdited writes b[from-1:to-1] to w, applying all edits within.
+func writeEdited(w io.Writer, b []byte, from, to token.Pos, edits []edit) error {
+	prev := from
+	for _, ed := range edits {
+		if ed.pos < from || ed.end > to {
+			continue
+		}
+		if _, err := w.Write(b[prev-1 : ed.pos-1]); err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte(ed.text)); err != nil {
+			return err
+		}
+		prev = ed.end
+	}
+	_, err := w.Write(b[prev-1 : to-1])
+	return err
+}
 
-	b, err := ioutil.ReadFile(filename)
+// addImports returns src with imports of the specified import paths added.
+func addImports(src []byte, imports []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("parsing formatted source: %v", err)
+	}
+	for _, path := range imports {
+		astutil.AddImport(fset, f, path)
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return nil, fmt.Errorf("formatting source: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// parsePackage parses the files of the package containing filename, starting
+// with e.file, which is already parsed.
+func (e *expansion) parsePackage(buildctx *build.Context, filename string) ([]*ast.File, error) {
+	names := e.pkgFiles
+	if names == nil {
+		var err error
+		if names, err = packageFiles(buildctx, filename, e.file.Name.Name); err != nil {
+			return nil, err
+		}
+	}
+	files := []*ast.File{e.file}
+	// TODO: parallelize
+	for _, n := range names {
+		n = filepath.Base(n)
+		if n == filepath.Base(filename) {
+			continue // already parsed
+		}
+		path := filepath.Join(filepath.Dir(filename), n)
+		src, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(e.fset, path, src, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("parsing: %v", err)
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func logic(w io.Writer, buildctx *build.Context, posn, noReturnStr string) error {
+	e := expansion{
+		fset: token.NewFileSet(),
+	}
+
+	// Short-cut: parse+type-check a single file before loading the entire
+	// package.
+	filename, query, err := splitPos(posn)
 	if err != nil {
 		return err
 	}
+	if len(overlay) > 0 {
+		ctx := *buildctx
+		ctx.OpenFile = openFile
+		buildctx = &ctx
+	}
+	b, err := readFile(filename)
+	if err != nil {
+		return err
+	}
+
+	e.file, err = parser.ParseFile(e.fset, filename, b, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("parsing: %v", err)
+	}
+	e.src = b
+
+	// TODO(golang.org/issues/21418): hack: importer.For always uses
+	// build.Default, so we need to change build.Default
+	build.Default = *buildctx
+
+	var warnFunc = func(warning string) { e.warnings = append(e.warnings, warning) }
+	if moduleDir(buildctx, filepath.Dir(filename)) != "" {
+		e.importer, e.pkgFiles, err = loadPackage(buildctx, filename)
+		if err != nil {
+			warnFunc(fmt.Sprintf("loading the package using go/packages failed, falling back to GOPATH: %v", err))
+		}
+	}
+
+	if symbolQuery.MatchString(query) {
+		// e.g. "foo.go:(*Server).Start#3"
+		offset, err := e.resolveSymbol(buildctx, filename, query)
+		if err != nil {
+			return err
+		}
+		posn = fmt.Sprintf("%s:#%d", filename, offset)
+	}
+	_, offset, _, err := parsePos(posn)
+	if err != nil {
+		return err
+	}
+	e.path, err = parseQueryPos(e.fset, e.file, posn, false)
+	if err != nil {
+		return err
+	}
+	for offset > 0 && unicode.IsSpace(rune(b[offset-1])) {
+		offset--
+	}
+	e.cursor = e.fset.File(e.file.Pos()).Pos(offset)
+
+	if err := e.typeCheck("main", []*ast.File{e.file}, warnFunc); err != nil {
+		if err != errUnknownSignature {
+			return err
+		}
+
+		// Parse all files, type-check again.
+		files, err := e.parsePackage(buildctx, filename)
+		if err != nil {
+			return err
+		}
+		if err := e.typeCheck(e.pkg.Name(), files, warnFunc); err != nil {
+			if err == errUnknownSignature && e.callee == nil && e.ce != nil {
+				return fmt.Errorf("could not determine the signature of %s", types.ExprString(e.ce.Fun))
+			}
+			return err
+		}
+	}
+
+	var subject ast.Node // what will be replaced
+	var repl []ast.Node
+	if e.commaOk != nil {
+		subject, repl, err = e.expandCommaOk(noReturnStr, e.commaOk)
+	} else if ds := e.deferStmt(); ds != nil {
+		subject, repl, err = e.expandDefer(ds)
+	} else if e.chain != nil {
+		subject, repl, err = e.expandChain(noReturnStr)
+	} else if ds, spec := e.valueSpec(); spec != nil {
+		subject, repl, err = e.expandDecl(noReturnStr, ds, spec)
+	} else if e.nestedCall() {
+		subject, repl, err = e.expandHoist(noReturnStr)
+	} else if e.isCommaOkCall() {
+		subject, repl, err = e.expandCommaOk(noReturnStr, e.ce)
+	} else {
+		subject, repl, err = e.expandCall(noReturnStr)
+	}
+	if err != nil {
+		return err
+	}
+
+	// TODO(golang.org/issues/20744): switch from textual replacement to
+	// formatting the AST once comments are represented in a more convenient
+	// way.
+
 	var src bytes.Buffer
-	// Copy everything before the subject as-is.
-	if _, err := src.Write(b[:subject.Pos()-1]); err != nil {
+	// Copy everything before the subject as-is, apart from the edits.
+	if err := writeEdited(&src, b, 1, subject.Pos(), e.edits); err != nil {
 		return err
 	}
+	// The expression under the cursor, which is retained in the replacement.
+	var orig ast.Expr = e.ce
+	if e.commaOk != nil {
+		orig = e.commaOk
+	}
 	var newEnd int
 	// Print the replacement
 	for _, node := range repl {
@@ -665,13 +1190,13 @@ func logic(w io.Writer, buildctx *build.Context, posn, noReturnStr string) error
 		if err := format.Node(&stmtFmt, token.NewFileSet(), node); err != nil {
 			return fmt.Errorf("formatting replacement: %v", err)
 		}
-		if err := format.Node(&ceFmt, e.fset, e.ce); err != nil {
+		if err := format.Node(&ceFmt, e.fset, orig); err != nil {
 			return fmt.Errorf("formatting replacement: %v", err)
 		}
 
 		newEnd += len(strings.Split(stmtFmt.String(), "\n"))
 
-		ceOrig := string(b[e.ce.Pos()-1 : e.ce.End()-1])
+		ceOrig := string(b[orig.Pos()-1 : orig.End()-1])
 		if _, err := src.Write([]byte(strings.Replace(stmtFmt.String(), ceFmt.String(), ceOrig, 1))); err != nil {
 			return err
 		}
@@ -680,8 +1205,8 @@ func logic(w io.Writer, buildctx *build.Context, posn, noReturnStr string) error
 			return err
 		}
 	}
-	// Copy everything after the subject as-is.
-	if _, err := src.Write(b[subject.End():]); err != nil {
+	// Copy everything after the subject as-is, apart from the edits.
+	if err := writeEdited(&src, b, subject.End(), token.Pos(len(b)+1), e.edits); err != nil {
 		return err
 	}
 
@@ -693,8 +1218,17 @@ func logic(w io.Writer, buildctx *build.Context, posn, noReturnStr string) error
 	}
 
 	if *formatFlag == "json" {
+		// Imports are not added to the lines, as they are not within the
+		// replaced range. Editors add them separately.
 		start := e.fset.Position(subject.Pos()).Line
 		end := e.fset.Position(subject.End()).Line
+		if len(e.edits) > 0 {
+			// Extend the replaced range to cover all edits. Everything after
+			// the range is unchanged, which determines its new length.
+			start = min(start, e.fset.Position(e.edits[0].pos).Line)
+			end = max(end, e.fset.Position(e.edits[len(e.edits)-1].end).Line)
+			newEnd = strings.Count(string(formatted), "\n") - strings.Count(string(b), "\n") + end - start + 1
+		}
 		var lines []string
 		for idx, line := range strings.Split(string(formatted), "\n") {
 			if idx >= start-1 && idx < start-1+newEnd {
@@ -705,19 +1239,27 @@ func logic(w io.Writer, buildctx *build.Context, posn, noReturnStr string) error
 			Start    int      `json:"start"`
 			End      int      `json:"end"`
 			Lines    []string `json:"lines"`
+			Imports  []string `json:"imports,omitempty"`
 			Warnings []string `json:"warnings"`
 		}{
 			Start:    start,
 			End:      end,
 			Lines:    lines,
-			Warnings: warnings,
+			Imports:  e.imports,
+			Warnings: e.warnings,
 		}); err != nil {
 			return err
 		}
 	} else {
-		for _, w := range warnings {
+		for _, w := range e.warnings {
 			log.Print(w)
 		}
+		if len(e.imports) > 0 {
+			formatted, err = addImports(formatted, e.imports)
+			if err != nil {
+				return err
+			}
+		}
 		if _, err := w.Write(formatted); err != nil {
 			return err
 		}
@@ -731,6 +1273,16 @@ var (
 	formatFlag     = flag.String("format", "", "output format (source, json). defaults to 'source'")
 	cpuprofile     = flag.String("cpuprofile", "", "write cpu profile `file`")
 	noErrReturnStr = flag.String("no-error-callback", "", "function call to be used if there is no error return value. ex: 'log.Fatalf(\"boom: %v\", err)'. defaults to 'panic(err)'")
+	bareReturn     = flag.Bool("bare-return", false, "in functions with named results, assign to the named error result and use a bare return statement")
+	wrapFlag       = flag.Bool("wrap", false, "wrap returned errors with context derived from the call, e.g. 'fmt.Errorf(\"remove %q: %w\", path, err)'")
+	httpStatus     = flag.Int("http-status", 500, "HTTP status code with which errors are reported to the client within HTTP handlers")
+	tagsFlag       = flag.String("tags", "", "comma-separated list of build tags to consider satisfied when selecting the files of the package")
+	goosFlag       = flag.String("goos", build.Default.GOOS, "target operating system when selecting the files of the package")
+	goarchFlag     = flag.String("goarch", build.Default.GOARCH, "target architecture when selecting the files of the package")
+	stdinFlag      = flag.Bool("stdin", false, "read the contents of the file under cursor from stdin instead of from disk, e.g. for unsaved editor buffers")
+	filenameFlag   = flag.String("filename", "", "with -stdin, the name of the file under cursor if the position is given as ':#offset' or ':line:column'")
+	utf16Flag      = flag.Bool("utf16", false, "count the columns of line:column positions in UTF-16 code units, as LSP clients do, instead of in bytes")
+	overlayFlag    = flag.String("overlay", "", "JSON file in the format of 'go build -overlay' which replaces the contents of files, e.g. '{\"Replace\": {\"/src/foo.go\": \"/tmp/foo.go\"}}'")
 )
 
 func main() {
@@ -753,6 +1305,28 @@ func main() {
 		os.Exit(2)
 	}
 	posn := args[0]
+	if *overlayFlag != "" {
+		if err := readOverlay(*overlayFlag); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *stdinFlag {
+		// e.g. -stdin -filename=foo.go :#123 or :12:7
+		if strings.HasPrefix(posn, ":") {
+			posn = *filenameFlag + posn
+		}
+		filename, _, err := splitPos(posn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		abs, err := filepath.Abs(filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if overlay[abs], err = ioutil.ReadAll(os.Stdin); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	o := io.Writer(os.Stdout)
 	if *wFlag != "" {
@@ -764,7 +1338,14 @@ func main() {
 		o = f
 	}
 
-	if err := logic(o, &build.Default, posn, *noErrReturnStr); err != nil {
+	buildctx := build.Default
+	buildctx.GOOS = *goosFlag
+	buildctx.GOARCH = *goarchFlag
+	if *tagsFlag != "" {
+		buildctx.BuildTags = strings.FieldsFunc(*tagsFlag, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+
+	if err := logic(o, &buildctx, posn, *noErrReturnStr); err != nil {
 		if *formatFlag == "json" {
 			jsonErr := json.NewEncoder(o).Encode(struct {
 				Error string `json:"error"`
@@ -7,10 +7,28 @@ import (
 	"go/build"
 	"io/ioutil"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 )
 
+// testBuildContext returns a build context for the GOPATH workspace gopath
+// (e.g. testdata/singleerror.got).
+func testBuildContext(t *testing.T, gopath string) *build.Context {
+	t.Helper()
+	gopath, err := filepath.Abs(gopath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &build.Context{
+		GOARCH:   build.Default.GOARCH,
+		GOOS:     build.Default.GOOS,
+		GOROOT:   build.Default.GOROOT,
+		GOPATH:   gopath,
+		Compiler: build.Default.Compiler,
+	}
+}
+
 func TestExpand(t *testing.T) {
 	// Cannot be safely run in parallel as long as build.Default is overridden
 	// t.Parallel()
@@ -20,30 +38,102 @@ func TestExpand(t *testing.T) {
 		fn          string
 		posn        string
 		errcallback string
+		flags       map[string]string
+		want        string // defaults to fn within the .want directory
 	}{
-		{"SingleErrorAfter", "testdata/singleerror.got/src/singleerror/singleerror.go", ":#90", ""},
-		{"SingleErrorBefore", "testdata/singleerror.got/src/singleerror/singleerror.go", ":#69", ""},
-		{"SingleErrorMiddle", "testdata/singleerror.got/src/singleerror/singleerror.go", ":#81", ""},
-		{"NoReturn", "testdata/nocalleereturn.got/src/nocalleereturn/nocalleereturn.go", ":#75", ""},
-		{"VariableAndError", "testdata/varanderror.got/src/varanderror/varanderror.go", ":#148", ""},
-		{"Comment", "testdata/comment.got/src/comment/comment.go", ":#90", ""},
-		{"CommentInline", "testdata/commentinline.got/src/commentinline/commentinline.go", ":#109", ""},
-		{"NoReturnCaller", "testdata/noreturncaller.got/src/noreturncaller/noreturncaller.go", ":#77", ""},
-		{"NoErrReturn", "testdata/noerrreturn.got/src/noerrreturn/noerrreturn.go", ":#81", ""},
-		{"ReturnErrCall", "testdata/returnerrcall.got/src/returnerrcall/returnerrcall.go", ":#101", "log.Fatal(err.Error())"},
-		{"FunctionLiteral", "testdata/functionliteral.got/src/functionliteral/functionliteral.go", ":#87", ""},
+		{name: "SingleErrorAfter", fn: "testdata/singleerror.got/src/singleerror/singleerror.go", posn: ":#90"},
+		{name: "SingleErrorBefore", fn: "testdata/singleerror.got/src/singleerror/singleerror.go", posn: ":#69"},
+		{name: "SingleErrorMiddle", fn: "testdata/singleerror.got/src/singleerror/singleerror.go", posn: ":#81"},
+		{name: "NoReturn", fn: "testdata/nocalleereturn.got/src/nocalleereturn/nocalleereturn.go", posn: ":#75"},
+		{name: "VariableAndError", fn: "testdata/varanderror.got/src/varanderror/varanderror.go", posn: ":#148"},
+		{name: "Comment", fn: "testdata/comment.got/src/comment/comment.go", posn: ":#90"},
+		{name: "CommentInline", fn: "testdata/commentinline.got/src/commentinline/commentinline.go", posn: ":#109"},
+		{name: "NoReturnCaller", fn: "testdata/noreturncaller.got/src/noreturncaller/noreturncaller.go", posn: ":#77"},
+		{name: "NoErrReturn", fn: "testdata/noerrreturn.got/src/noerrreturn/noerrreturn.go", posn: ":#81"},
+		{name: "ReturnErrCall", fn: "testdata/returnerrcall.got/src/returnerrcall/returnerrcall.go", posn: ":#101", errcallback: "log.Fatal(err.Error())"},
+		{name: "FunctionLiteral", fn: "testdata/functionliteral.got/src/functionliteral/functionliteral.go", posn: ":#87"},
 		// The following test spreads out one package over two files, exercising
 		// the code path for loading multiple files.
-		{"2Files1Pkg", "testdata/pkg.got/src/pkg/pkg2.go", ":#49", ""},
+		{name: "2Files1Pkg", fn: "testdata/pkg.got/src/pkg/pkg2.go", posn: ":#49"},
 		// MultiPkg calls a function in another not-compiled, non-stdlib package.
-		{"MultiPkg", "testdata/multipkg.got/src/multipkg/multipkg.go", ":#79", ""},
-		{"MultiPkgVendor", "testdata/multipkgvendor.got/src/multipkg/multipkg.go", ":#79", ""},
-		{"Underscore", "testdata/underscore.got/src/underscore/underscore.go", ":#162", ""},
-		{"IntroduceErr", "testdata/introduceerr.got/src/introduceerr/introduceerr.go", ":#176", ""},
-		{"NoIntroduce", "testdata/nointroduce.got/src/nointroduce/nointroduce.go", ":#165", ""},
-		{"PresentSingle", "testdata/presentsingle.got/src/presentsingle/presentsingle.go", ":#90", ""},
-		{"PresentDouble", "testdata/presentdouble.got/src/presentdouble/presentdouble.go", ":#105", ""},
-		{"CustomTypes", "testdata/customtypes.got/src/customtypes/customtypes.go", ":#191", ""},
+		{name: "MultiPkg", fn: "testdata/multipkg.got/src/multipkg/multipkg.go", posn: ":#79"},
+		{name: "MultiPkgVendor", fn: "testdata/multipkgvendor.got/src/multipkg/multipkg.go", posn: ":#79"},
+		{name: "Underscore", fn: "testdata/underscore.got/src/underscore/underscore.go", posn: ":#162"},
+		{name: "IntroduceErr", fn: "testdata/introduceerr.got/src/introduceerr/introduceerr.go", posn: ":#176"},
+		{name: "NoIntroduce", fn: "testdata/nointroduce.got/src/nointroduce/nointroduce.go", posn: ":#165"},
+		{name: "PresentSingle", fn: "testdata/presentsingle.got/src/presentsingle/presentsingle.go", posn: ":#90"},
+		{name: "PresentDouble", fn: "testdata/presentdouble.got/src/presentdouble/presentdouble.go", posn: ":#105"},
+		{name: "CustomTypes", fn: "testdata/customtypes.got/src/customtypes/customtypes.go", posn: ":#191"},
+		{name: "ZeroValues", fn: "testdata/zerovalues.got/src/zerovalues/zerovalues.go", posn: ":#238"},
+		{name: "ZeroValuesPkg", fn: "testdata/zerovaluespkg.got/src/zerovaluespkg/zerovaluespkg.go", posn: ":#98"},
+		{name: "Generics", fn: "testdata/generics.got/src/generics/generics.go", posn: ":#237"},
+		{name: "GenericsIndexList", fn: "testdata/genericsindexlist.got/src/genericsindexlist/genericsindexlist.go", posn: ":#210"},
+		{name: "GenericMethod", fn: "testdata/genericsmethod.got/src/genericsmethod/genericsmethod.go", posn: ":#331"},
+		{name: "MessageTypeNotImported", fn: "testdata/qualifier.got/src/qualifier/qualifier.go", posn: ":#79"},
+		{name: "CallStructField", fn: "testdata/callexprfield.got/src/callexprfield/callexprfield.go", posn: ":#124"},
+		{name: "CallSliceElement", fn: "testdata/callexprelement.got/src/callexprelement/callexprelement.go", posn: ":#80"},
+		{name: "CallReturnedFunc", fn: "testdata/callexprreturned.got/src/callexprreturned/callexprreturned.go", posn: ":#133"},
+		{name: "CallMethodExpr", fn: "testdata/callexprmethodexpr.got/src/callexprmethodexpr/callexprmethodexpr.go", posn: ":#175"},
+		{name: "ErrorAlias", fn: "testdata/erroralias.got/src/erroralias/erroralias.go", posn: ":#356"},
+		{name: "ErrorConcrete", fn: "testdata/errorconcrete.got/src/errorconcrete/errorconcrete.go", posn: ":#428"},
+		{name: "NamedResult", fn: "testdata/namedresult.got/src/namedresult/namedresult.go", posn: ":#237"},
+		{name: "NamedResultBareReturn", fn: "testdata/namedresultbare.got/src/namedresultbare/namedresultbare.go", posn: ":#275", flags: map[string]string{"bare-return": "true"}},
+		{name: "BareStatement", fn: "testdata/barestmt.got/src/barestmt/barestmt.go", posn: ":#221"},
+		{name: "BareStatementCollision", fn: "testdata/barestmtcollision.got/src/barestmtcollision/barestmtcollision.go", posn: ":#303"},
+		{name: "BareStatementUnused", fn: "testdata/barestmtunused.got/src/barestmtunused/barestmtunused.go", posn: ":#340"},
+		{name: "BareStatementInAssignedClosure", fn: "testdata/assignedclosure.got/src/assignedclosure/assignedclosure.go", posn: ":#74"},
+		{name: "CommaOkMap", fn: "testdata/commaokmap.got/src/commaokmap/commaokmap.go", posn: ":#93"},
+		{name: "CommaOkTypeAssertion", fn: "testdata/commaokassert.got/src/commaokassert/commaokassert.go", posn: ":#173"},
+		{name: "CommaOkReceive", fn: "testdata/commaokrecv.got/src/commaokrecv/commaokrecv.go", posn: ":#250"},
+		{name: "CommaOkCall", fn: "testdata/commaokcall.got/src/commaokcall/commaokcall.go", posn: ":#309"},
+		{name: "DeferClose", fn: "testdata/deferclose.got/src/deferclose/deferclose.go", posn: ":#155"},
+		{name: "DeferCloseJoin", fn: "testdata/deferclosejoin.got/src/deferclosejoin/deferclosejoin.go", posn: ":#150"},
+		{name: "DeferCloseWorkspace", fn: "testdata/deferclosework.got/src/deferclosework/deferclosework.go", posn: ":#150"},
+		{name: "DeferCloseErrDeclared", fn: "testdata/deferclosedefine.got/src/deferclosedefine/deferclosedefine.go", posn: ":#117"},
+		{name: "DeferCloseErrParam", fn: "testdata/deferclosedparam.got/src/deferclosedparam/deferclosedparam.go", posn: ":#80"},
+		{name: "GoroutineChan", fn: "testdata/goroutinechan.got/src/goroutinechan/goroutinechan.go", posn: ":#166"},
+		{name: "GoroutineChanInnermost", fn: "testdata/goroutinechans.got/src/goroutinechans/goroutinechans.go", posn: ":#196"},
+		{name: "GoroutineErrgroup", fn: "testdata/goroutineerrgroup.got/src/goroutineerrgroup/goroutineerrgroup.go", posn: ":#187"},
+		{name: "GoroutineLog", fn: "testdata/goroutinelog.got/src/goroutinelog/goroutinelog.go", posn: ":#97"},
+		{name: "GoroutineCallback", fn: "testdata/goroutinecallback.got/src/goroutinecallback/goroutinecallback.go", posn: ":#97", errcallback: "log.Fatal(err)"},
+		{name: "FatalMain", fn: "testdata/fatalmain.got/src/fatalmain/fatalmain.go", posn: ":#69"},
+		{name: "FatalMainExit", fn: "testdata/fatalmainexit.got/src/fatalmainexit/fatalmainexit.go", posn: ":#171"},
+		{name: "FatalInit", fn: "testdata/fatalinit.got/src/fatalinit/fatalinit.go", posn: ":#88"},
+		{name: "TestFatal", fn: "testdata/testfatal.got/src/testfatal/testfatal_test.go", posn: ":#181"},
+		{name: "TestFatalRenamedImport", fn: "testdata/testfatalrenamed.got/src/testfatalrenamed/testfatalrenamed_test.go", posn: ":#124"},
+		{name: "TestRequire", fn: "testdata/testrequire.got/src/testrequire/testrequire_test.go", posn: ":#202"},
+		{name: "HTTPHandler", fn: "testdata/httphandler.got/src/httphandler/httphandler.go", posn: ":#202"},
+		{name: "HTTPHandlerRenamedImport", fn: "testdata/httphandlerrenamed.got/src/httphandlerrenamed/httphandlerrenamed.go", posn: ":#214"},
+		{name: "HTTPHandlerStatus", fn: "testdata/httphandlerstatus.got/src/httphandlerstatus/httphandlerstatus.go", posn: ":#202", flags: map[string]string{"http-status": "400"}},
+		{name: "HoistArgument", fn: "testdata/hoistarg.got/src/hoistarg/hoistarg.go", posn: ":#203"},
+		{name: "HoistArgumentCallee", fn: "testdata/hoistarg.got/src/hoistarg/hoistarg.go", posn: ":#198"},
+		{name: "HoistArgumentArgs", fn: "testdata/hoistarg.got/src/hoistarg/hoistarg.go", posn: ":#202"},
+		{name: "HoistCompositeLit", fn: "testdata/hoistlit.got/src/hoistlit/hoistlit.go", posn: ":#199"},
+		{name: "MethodChain", fn: "testdata/chain.got/src/chain/chain.go", posn: ":#433"},
+		{name: "ErrInspectedByDefer", fn: "testdata/errdefer.got/src/errdefer/errdefer.go", posn: ":#208"},
+		{name: "ErrRedeclared", fn: "testdata/errredeclare.got/src/errredeclare/errredeclare.go", posn: ":#287"},
+		{name: "ErrSlotBlank", fn: "testdata/errslotblank.got/src/errslotblank/errslotblank.go", posn: ":#199"},
+		{name: "ErrSlotName", fn: "testdata/errslotname.got/src/errslotname/errslotname.go", posn: ":#101"},
+		{name: "ErrSlotUsedAfter", fn: "testdata/errusedafter.got/src/errusedafter/errusedafter.go", posn: ":#75"},
+		{name: "VarDecl", fn: "testdata/vardecl.got/src/vardecl/vardecl.go", posn: ":#210"},
+		{name: "VarDeclComments", fn: "testdata/vardeclcomments.got/src/vardeclcomments/vardeclcomments.go", posn: ":#310"},
+		{name: "VarDeclTyped", fn: "testdata/vardecltyped.got/src/vardecltyped/vardecltyped.go", posn: ":#172"},
+		{name: "VarDeclErrorOnly", fn: "testdata/vardeclerror.got/src/vardeclerror/vardeclerror.go", posn: ":#83"},
+		{name: "HeaderIf", fn: "testdata/headerif.got/src/headerif/headerif.go", posn: ":#144"},
+		{name: "HeaderSwitch", fn: "testdata/headerswitch.got/src/headerswitch/headerswitch.go", posn: ":#132"},
+		{name: "LoopCond", fn: "testdata/loopcond.got/src/loopcond/loopcond.go", posn: ":#159"},
+		{name: "ModuleReplace", fn: "testdata/modreplace.got/src/app/app.go", posn: ":#102"},
+		{name: "BuildConstraints", fn: "testdata/buildconstraints.got/src/buildconstraints/run.go", posn: ":#68"},
+		{name: "LineColumn", fn: "testdata/singleerror.got/src/singleerror/singleerror.go", posn: ":9:23"},
+		{name: "LineColumnRange", fn: "testdata/singleerror.got/src/singleerror/singleerror.go", posn: ":9:2-9:23"},
+		{name: "LineColumnUTF16", fn: "testdata/utf16.got/src/utf16/utf16.go", posn: ":6:24", flags: map[string]string{"utf16": "true"}},
+		{name: "SymbolFunc", fn: "testdata/singleerror.got/src/singleerror/singleerror.go", posn: ":logic"},
+		{name: "SymbolMethod", fn: "testdata/symbol.got/src/symbol/symbol.go", posn: ":(*Server).Start#2"},
+		{name: "Wrap", fn: "testdata/wrap.got/src/wrap/wrap.go", posn: ":#95", flags: map[string]string{"wrap": "true"}},
+		{name: "WrapCollision", fn: "testdata/wrapcollision.got/src/wrapcollision/wrapcollision.go", posn: ":#112", flags: map[string]string{"wrap": "true"}},
+		{name: "WrapMulti", fn: "testdata/wrapmulti.got/src/wrapmulti/wrapmulti.go", posn: ":#218", flags: map[string]string{"wrap": "true"}},
+		{name: "WrapPkgErrorsf", fn: "testdata/pkgerrors.got/src/pkgerrors/pkgerrors.go", posn: ":#199", flags: map[string]string{"wrap": "true"}},
+		{name: "WrapPkgErrors", fn: "testdata/pkgerrorswrap.got/src/pkgerrors/pkgerrors.go", posn: ":#261", flags: map[string]string{"wrap": "true"}},
 	} {
 		entry := entry // copy
 		t.Run(entry.name, func(t *testing.T) {
@@ -51,26 +141,25 @@ func TestExpand(t *testing.T) {
 			// t.Parallel()
 
 			flag.Set("format", "source")
-
-			wantContents, err := ioutil.ReadFile(strings.Replace(entry.fn, ".got", ".want", 1))
-			if err != nil {
-				t.Fatal(err)
+			for name, value := range entry.flags {
+				old := flag.Lookup(name).Value.String()
+				flag.Set(name, value)
+				defer flag.Set(name, old)
 			}
 
-			gopath, err := filepath.Abs(filepath.Join(strings.Split(entry.fn, "/")[:2]...))
+			wantFn := entry.want
+			if wantFn == "" {
+				wantFn = strings.Replace(entry.fn, ".got", ".want", 1)
+			}
+			wantContents, err := ioutil.ReadFile(wantFn)
 			if err != nil {
 				t.Fatal(err)
 			}
-			buildctx := build.Context{
-				GOARCH:   build.Default.GOARCH,
-				GOOS:     build.Default.GOOS,
-				GOROOT:   build.Default.GOROOT,
-				GOPATH:   gopath,
-				Compiler: build.Default.Compiler,
-			}
+
+			buildctx := testBuildContext(t, filepath.Join(strings.Split(entry.fn, "/")[:2]...))
 
 			var buf bytes.Buffer
-			if err := logic(&buf, &buildctx, entry.fn+entry.posn, entry.errcallback); err != nil {
+			if err := logic(&buf, buildctx, entry.fn+entry.posn, entry.errcallback); err != nil {
 				t.Fatal(err)
 			}
 
@@ -88,7 +177,7 @@ func TestExpand(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			if err := logic(&buf, &buildctx, entry.fn+entry.posn, entry.errcallback); err != nil {
+			if err := logic(&buf, buildctx, entry.fn+entry.posn, entry.errcallback); err != nil {
 				t.Fatal(err)
 			}
 
@@ -96,6 +185,7 @@ func TestExpand(t *testing.T) {
 				Start    int      `json:"start"`
 				End      int      `json:"end"`
 				Lines    []string `json:"lines"`
+				Imports  []string `json:"imports"`
 				Warnings []string `json:"warnings"`
 			}
 			if err := json.Unmarshal(buf.Bytes(), &change); err != nil {
@@ -109,9 +199,133 @@ func TestExpand(t *testing.T) {
 			replaced = append(replaced, change.Lines...)
 			replaced = append(replaced, lines[change.End:]...)
 
-			if got, want := strings.Join(replaced, "\n"), string(wantContents); got != want {
+			got := strings.Join(replaced, "\n")
+			if len(change.Imports) > 0 {
+				b, err := addImports([]byte(got), change.Imports)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = string(b)
+			}
+
+			if want := string(wantContents); got != want {
 				t.Fatalf("unexpected result: have:\n%s\nwant:\n%s", got, want)
 			}
 		})
 	}
 }
+
+func TestExpandErrors(t *testing.T) {
+	for _, entry := range []struct {
+		name    string
+		fn      string
+		posn    string
+		wantErr string
+	}{
+		{"Conversion", "testdata/callexpr.got/src/callexpr/callexpr.go", ":#84", "name(x) is a conversion to type main.name, not a function call"},
+		{"Builtin", "testdata/callexpr.got/src/callexpr/callexpr.go", ":#163", "this is a call to the built-in 'close' operator"},
+		{"CommaOkAssignedTo", "testdata/commaokassign.got/src/commaokassign/commaokassign.go", ":#71", "cannot expand m[k]: it is assigned to, not assigned from"},
+	} {
+		entry := entry // copy
+		t.Run(entry.name, func(t *testing.T) {
+			buildctx := testBuildContext(t, filepath.Join(strings.Split(entry.fn, "/")[:2]...))
+
+			flag.Set("format", "source")
+			var buf bytes.Buffer
+			err := logic(&buf, buildctx, entry.fn+entry.posn, "")
+			if err == nil {
+				t.Fatalf("unexpectedly succeeded:\n%s", buf.String())
+			}
+			if got, want := err.Error(), entry.wantErr; got != want {
+				t.Fatalf("unexpected error: got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	for _, entry := range []struct {
+		name string
+		fn   string
+		posn string
+		want []string // warnings which must be emitted
+	}{
+		{"HoistReordering", "testdata/hoistlit.got/src/hoistlit/hoistlit.go", ":#199", []string{`hoisting strconv.Atoi(os.Getenv("PORT")) changes the evaluation order: os.Getenv("HOST") used to be evaluated first`}},
+	} {
+		entry := entry // copy
+		t.Run(entry.name, func(t *testing.T) {
+			buildctx := testBuildContext(t, filepath.Join(strings.Split(entry.fn, "/")[:2]...))
+
+			flag.Set("format", "json")
+			defer flag.Set("format", "source")
+			var buf bytes.Buffer
+			if err := logic(&buf, buildctx, entry.fn+entry.posn, ""); err != nil {
+				t.Fatal(err)
+			}
+			var change struct {
+				Warnings []string `json:"warnings"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &change); err != nil {
+				t.Fatal(err)
+			}
+			// Warnings about ignored type-checking errors are not checked.
+			have := make(map[string]bool)
+			for _, w := range change.Warnings {
+				have[w] = true
+			}
+			for _, w := range entry.want {
+				if !have[w] {
+					t.Errorf("missing warning %q, have:\n%s", w, strings.Join(change.Warnings, "\n"))
+				}
+			}
+		})
+	}
+}
+
+func TestParsePos(t *testing.T) {
+	const fn = "testdata/utf16.got/src/utf16/utf16.go"
+	for _, entry := range []struct {
+		posn       string
+		utf16      bool
+		start, end int
+	}{
+		{":#90", false, 90, 90},
+		{":#90,#95", false, 90, 95},
+		{":1:1", false, 0, 0},
+		{":6:2-6:4", false, 51, 53},
+		{":6:24", false, 73, 73},
+		{":6:24", true, 76, 76},
+	} {
+		flag.Set("utf16", strconv.FormatBool(entry.utf16))
+		filename, start, end, err := parsePos(fn + entry.posn)
+		if err != nil {
+			t.Errorf("parsePos(%q): %v", entry.posn, err)
+			continue
+		}
+		if filename != fn || start != entry.start || end != entry.end {
+			t.Errorf("parsePos(%q) = %q, %d, %d, want %q, %d, %d", entry.posn, filename, start, end, fn, entry.start, entry.end)
+		}
+	}
+	flag.Set("utf16", "false")
+}
+
+func TestOverlay(t *testing.T) {
+	if err := readOverlay("testdata/overlay.got/overlay.json"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { overlay = make(map[string][]byte) }()
+
+	wantContents, err := ioutil.ReadFile("testdata/overlay.want/src/overlay/overlay.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buildctx := testBuildContext(t, "testdata/overlay.got")
+	flag.Set("format", "source")
+	var buf bytes.Buffer
+	if err := logic(&buf, buildctx, "testdata/overlay.got/src/overlay/overlay.go:#68", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), string(wantContents); got != want {
+		t.Fatalf("unexpected result: have:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+// This file defines how errors are handled within main and init, which cannot
+// return them.
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+// enclosingFunc returns the name of the package-level function declaration
+// enclosing the cursor, or "" if the cursor is within a method or function
+// literal.
+func (e *expansion) enclosingFunc() string {
+	for _, n := range e.path {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Recv != nil {
+				return ""
+			}
+			return n.Name.Name
+		case *ast.FuncLit:
+			return ""
+		}
+	}
+	return ""
+}
+
+// usesOsExit returns whether the file under cursor calls os.Exit.
+func (e *expansion) usesOsExit() bool {
+	found := false
+	ast.Inspect(e.file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok && sel.Sel.Name == "Exit" {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				if pkg, ok := e.info.Uses[id].(*types.PkgName); ok && pkg.Imported().Path() == "os" {
+					found = true
+				}
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// mainOutput returns the statements terminating the program with errExpr,
+// e.g. log.Fatal(err). Programs which already use os.Exit print the error to
+// stderr and exit instead.
+func (e *expansion) mainOutput(errExpr ast.Expr) []ast.Stmt {
+	if e.usesOsExit() {
+		return []ast.Stmt{
+			&ast.ExprStmt{X: &ast.CallExpr{
+				Fun:  e.qualify("fmt", "Fprintln"),
+				Args: []ast.Expr{e.qualify("os", "Stderr"), errExpr},
+			}},
+			&ast.ExprStmt{X: &ast.CallExpr{
+				Fun:  e.qualify("os", "Exit"),
+				Args: []ast.Expr{&ast.BasicLit{Kind: token.INT, Value: "1"}},
+			}},
+		}
+	}
+	return []ast.Stmt{&ast.ExprStmt{X: &ast.CallExpr{
+		Fun:  e.qualify("log", "Fatal"),
+		Args: []ast.Expr{errExpr},
+	}}}
+}
+
+// initOutput returns a statement panicking with errExpr and context about the
+// call, e.g. panic(fmt.Sprintf("remove %q: %v", path, err)).
+func (e *expansion) initOutput(errExpr ast.Expr) []ast.Stmt {
+	arg := errExpr
+	if e.ce != nil && !*wrapFlag {
+		if msg, args := e.wrapContext(); msg != "" {
+			arg = &ast.CallExpr{
+				Fun: e.qualify("fmt", "Sprintf"),
+				Args: append(append([]ast.Expr{
+					&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(msg + ": %v")},
+				}, args...), errExpr),
+			}
+		}
+	}
+	return []ast.Stmt{&ast.ExprStmt{X: &ast.CallExpr{
+		Fun:  &ast.Ident{Name: "panic"},
+		Args: []ast.Expr{arg},
+	}}}
+}
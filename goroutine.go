@@ -0,0 +1,176 @@
+package main
+
+// This file defines how errors are handled within goroutines, where neither
+// returning nor panicking is appropriate.
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+// goStmt returns the go statement starting the function literal enclosing the
+// cursor, e.g. go func() { … }(), if any.
+func (e *expansion) goStmt() (*ast.GoStmt, *ast.FuncLit) {
+	for _, n := range e.path {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			return nil, nil
+		case *ast.FuncLit:
+			if ce, ok := e.parent(n).(*ast.CallExpr); ok && unparen(ce.Fun) == n {
+				if gs, ok := e.parent(ce).(*ast.GoStmt); ok {
+					return gs, n
+				}
+			}
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
+
+// inScope returns the variable which is in scope at the cursor and for which
+// match returns true, if any. Inner scopes are preferred, and within a scope
+// the most recently declared variable.
+func (e *expansion) inScope(match func(typ types.Type) bool) *types.Var {
+	pos := e.path[0].Pos()
+	for s := e.getScope(); s != nil && s != types.Universe; s = s.Parent() {
+		var found *types.Var
+		for _, name := range s.Names() {
+			v, ok := s.Lookup(name).(*types.Var)
+			if !ok || (s != e.pkg.Scope() && v.Pos() > pos) {
+				continue
+			}
+			if match(v.Type()) && (found == nil || v.Pos() > found.Pos()) {
+				found = v
+			}
+		}
+		if found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// isNamed returns whether typ (or the type it points to) is the named type
+// pkgPath.name.
+func isNamed(typ types.Type, pkgPath, name string) bool {
+	if ptr, ok := types.Unalias(typ).(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	named, ok := types.Unalias(typ).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	return unvendor(named.Obj().Pkg().Path()) == pkgPath && named.Obj().Name() == name
+}
+
+// hasReturn returns whether the body of fn contains a return statement (not
+// counting nested function literals).
+func hasReturn(fn *ast.FuncLit) bool {
+	found := false
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// goroutineOutput returns the statements handling the error errExpr within a
+// goroutine: returning it from an errgroup.Group’s function (converting the
+// go statement), sending it on a chan error or logging it. It returns nil if
+// none of these are possible.
+func (e *expansion) goroutineOutput(errExpr ast.Expr) []ast.Stmt {
+	gs, fn := e.goStmt()
+	if gs == nil {
+		return nil
+	}
+
+	// e.g. go func() { … }() → g.Go(func() error { …; return nil })
+	if len(gs.Call.Args) == 0 && fn.Type.Params.NumFields() == 0 && fn.Type.Results.NumFields() == 0 && !hasReturn(fn) {
+		g := e.inScope(func(typ types.Type) bool {
+			return isNamed(typ, "golang.org/x/sync/errgroup", "Group")
+		})
+		if g != nil {
+			e.edits = append(e.edits,
+				edit{pos: gs.Pos(), end: fn.Type.End(), text: g.Name() + ".Go(func() error"},
+				edit{pos: fn.Body.Rbrace, end: gs.End(), text: "return nil\n})"})
+			return []ast.Stmt{&ast.ReturnStmt{Results: []ast.Expr{errExpr}}}
+		}
+	}
+
+	// e.g. errc <- err; return
+	errc := e.inScope(func(typ types.Type) bool {
+		ch, ok := typ.Underlying().(*types.Chan)
+		return ok && ch.Dir() != types.RecvOnly && isError(ch.Elem())
+	})
+	if errc != nil {
+		return []ast.Stmt{
+			&ast.SendStmt{Chan: &ast.Ident{Name: errc.Name()}, Value: errExpr},
+			&ast.ReturnStmt{},
+		}
+	}
+
+	if call := e.logCall(errExpr); call != nil {
+		return []ast.Stmt{&ast.ExprStmt{X: call}, &ast.ReturnStmt{}}
+	}
+	return nil
+}
+
+// logCall returns a call logging errExpr using a *slog.Logger or *log.Logger
+// in scope or, failing that, the log/slog or log package imported by the file
+// under cursor. It returns nil if no logger was found.
+func (e *expansion) logCall(errExpr ast.Expr) ast.Expr {
+	msg, format := "error", "%v"
+	var args []ast.Expr
+	if e.ce != nil {
+		if name := calleeName(e.ce); name != "" {
+			msg = splitCamelCase(name)
+		}
+		if context, contextArgs := e.wrapContext(); context != "" {
+			format, args = context+": %v", contextArgs
+		}
+	}
+	slogCall := func(fun ast.Expr) ast.Expr {
+		// e.g. logger.Error("remove", "err", err)
+		return &ast.CallExpr{
+			Fun: fun,
+			Args: []ast.Expr{
+				&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(msg)},
+				&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote("err")},
+				errExpr,
+			},
+		}
+	}
+	logCall := func(fun ast.Expr) ast.Expr {
+		// e.g. log.Printf("remove %q: %v", path, err)
+		return &ast.CallExpr{
+			Fun: fun,
+			Args: append(append([]ast.Expr{
+				&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(format)},
+			}, args...), errExpr),
+		}
+	}
+	selector := func(x, sel string) ast.Expr {
+		return &ast.SelectorExpr{X: &ast.Ident{Name: x}, Sel: &ast.Ident{Name: sel}}
+	}
+
+	if v := e.inScope(func(typ types.Type) bool { return isNamed(typ, "log/slog", "Logger") }); v != nil {
+		return slogCall(selector(v.Name(), "Error"))
+	}
+	if v := e.inScope(func(typ types.Type) bool { return isNamed(typ, "log", "Logger") }); v != nil {
+		return logCall(selector(v.Name(), "Printf"))
+	}
+	if e.imported("log/slog") {
+		return slogCall(e.qualify("log/slog", "Error"))
+	}
+	if e.imported("log") {
+		return logCall(e.qualify("log", "Printf"))
+	}
+	return nil
+}
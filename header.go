@@ -0,0 +1,293 @@
+package main
+
+// This file defines the expansion of calls within the headers of if, switch
+// and for statements, e.g. if ok, err := check(); ok { → if ok, err :=
+// check(); err != nil { … } else if ok {.
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// span is a node covering the source between pos and end. It is used as the
+// subject of expansions which replace only part of a statement.
+type span struct{ pos, end token.Pos }
+
+func (s span) Pos() token.Pos { return s.pos }
+func (s span) End() token.Pos { return s.end }
+
+// headerOf returns the if, switch or for statement whose init (or post)
+// statement is subject, along with that statement.
+func (e *expansion) headerOf(subject ast.Node) (ast.Stmt, ast.Stmt) {
+	stmt, ok := subject.(ast.Stmt)
+	if !ok {
+		// e.g. f() in if f(); cond {
+		if stmt, ok = e.parent(subject).(*ast.ExprStmt); !ok {
+			return nil, nil
+		}
+	}
+	switch p := e.parent(stmt).(type) {
+	case *ast.IfStmt:
+		if p.Init == stmt {
+			return p, stmt
+		}
+	case *ast.SwitchStmt:
+		if p.Init == stmt {
+			return p, stmt
+		}
+	case *ast.TypeSwitchStmt:
+		if p.Init == stmt {
+			return p, stmt
+		}
+	case *ast.ForStmt:
+		if p.Init == stmt || p.Post == stmt {
+			return p, stmt
+		}
+	}
+	return nil, nil
+}
+
+// splitCheck returns the assignment and the error check of repl if repl
+// consists of nothing else, e.g. if _, err := f(); err != nil { … } or n, err
+// := f(); if err != nil { … }.
+func splitCheck(repl []ast.Node) (ast.Stmt, *ast.IfStmt) {
+	switch len(repl) {
+	case 1:
+		if check, ok := repl[0].(*ast.IfStmt); ok && check.Init != nil {
+			init := check.Init
+			return init, &ast.IfStmt{Cond: check.Cond, Body: check.Body}
+		}
+	case 2:
+		as, ok := repl[0].(*ast.AssignStmt)
+		check, ok2 := repl[1].(*ast.IfStmt)
+		if ok && ok2 && check.Init == nil {
+			return as, check
+		}
+	}
+	return nil, nil
+}
+
+// expandHeader places repl, the expansion of init within the header of hdr.
+// Within if statements, the error is checked before the condition, keeping
+// the scope of all variables. Otherwise, the init statement is hoisted before
+// hdr, which is wrapped in a block if hoisting would redeclare or shadow
+// variables.
+func (e *expansion) expandHeader(hdr, init ast.Stmt, repl []ast.Node) (ast.Node, []ast.Node, error) {
+	if fs, ok := hdr.(*ast.ForStmt); ok && fs.Post == init {
+		return nil, nil, fmt.Errorf("cannot check the error of %s within the post statement of the for loop: assign it and check it in the loop body", types.ExprString(e.ce))
+	}
+
+	if is, ok := hdr.(*ast.IfStmt); ok {
+		if as, check := splitCheck(repl); as != nil {
+			// e.g. if ok, err := check(); ok { → if ok, err := check(); err != nil { … } else if ok {
+			text, err := e.formatNodes(check)
+			if err != nil {
+				return nil, nil, err
+			}
+			e.addEdit(edit{
+				pos:  is.Cond.Pos(),
+				end:  is.Cond.Pos(),
+				text: strings.TrimPrefix(text, "if ") + " else if ",
+			})
+			return span{init.Pos(), is.Cond.Pos()}, []ast.Node{as}, nil
+		}
+	}
+
+	if _, ok := e.parent(hdr).(*ast.LabeledStmt); ok {
+		return nil, nil, fmt.Errorf("cannot hoist %s out of the labeled %s", types.ExprString(e.ce), astutil.NodeDescription(hdr))
+	}
+	// e.g. switch v, err := kind(x); v { → v, err := kind(x); if err != nil { … }; switch v {
+	var keyword string
+	var next token.Pos
+	switch hdr := hdr.(type) {
+	case *ast.IfStmt:
+		keyword, next = "if ", hdr.Cond.Pos()
+	case *ast.SwitchStmt:
+		keyword, next = "switch ", hdr.Body.Lbrace
+		if hdr.Tag != nil {
+			next = hdr.Tag.Pos()
+		}
+	case *ast.TypeSwitchStmt:
+		keyword, next = "switch ", hdr.Assign.Pos()
+	case *ast.ForStmt:
+		keyword, next = "for ", init.End() // keep the semicolon
+	}
+	e.addEdit(edit{pos: init.End(), end: next, text: "\n" + keyword})
+
+	names := declaredNames(repl)
+	if scope := e.enclosingScope(hdr); scope != nil {
+		for _, name := range names {
+			obj := lookup(scope, name, hdr.Pos())
+			if obj == nil || (obj.Parent() != scope && !e.usedWithin(obj, hdr.End(), scope.End())) {
+				continue
+			}
+			e.warn("wrapping the %s in a block: hoisting its init statement would redeclare or shadow %s declared at %s",
+				astutil.NodeDescription(hdr), name, e.fset.Position(obj.Pos()))
+			e.addEdit(edit{pos: hdr.Pos(), end: hdr.Pos(), text: "{\n"})
+			e.addEdit(edit{pos: hdr.End(), end: hdr.End(), text: "\n}"})
+			return span{hdr.Pos(), init.End()}, repl, nil
+		}
+	}
+	if _, ok := hdr.(*ast.ForStmt); ok && len(names) > 0 {
+		e.warn("hoisting the init statement out of the for loop: %s are no longer scoped to the loop",
+			strings.Join(names, ", "))
+	}
+	return span{hdr.Pos(), init.End()}, repl, nil
+}
+
+// declaredNames returns the names of the variables declared by repl, not
+// counting declarations within nested scopes.
+func declaredNames(repl []ast.Node) []string {
+	var names []string
+	for _, n := range repl {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok != token.DEFINE {
+				continue
+			}
+			for _, lhs := range n.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && id.Name != "_" {
+					names = append(names, id.Name)
+				}
+			}
+		case *ast.DeclStmt:
+			for _, spec := range n.Decl.(*ast.GenDecl).Specs {
+				for _, id := range spec.(*ast.ValueSpec).Names {
+					names = append(names, id.Name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// enclosingScope returns the innermost scope which encloses n.
+func (e *expansion) enclosingScope(n ast.Node) *types.Scope {
+	found := false
+	for _, p := range e.path {
+		if !found {
+			found = p == n
+			continue
+		}
+		if funcDecl, ok := p.(*ast.FuncDecl); ok {
+			return e.info.Scopes[funcDecl.Type]
+		}
+		if s, ok := e.info.Scopes[p]; ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// loopCond returns the for statement in whose condition e.ce is, if any.
+func (e *expansion) loopCond() *ast.ForStmt {
+	for _, n := range e.path {
+		fs, ok := n.(*ast.ForStmt)
+		if ok && fs.Cond != nil && fs.Cond.Pos() <= e.ce.Pos() && e.ce.End() <= fs.Cond.End() {
+			return fs
+		}
+		if _, ok := n.(ast.Stmt); ok {
+			return nil
+		}
+	}
+	return nil
+}
+
+// expandLoopCond moves the condition of fs into its body so that the error of
+// e.ce can be checked in each iteration, e.g. for scan() { … } → for { ok, err
+// := scan(); if err != nil { … }; if !ok { break }; … }. As continue
+// statements run the post statement before the (moved) condition, the
+// semantics are unchanged.
+func (e *expansion) expandLoopCond(noReturnStr string, fs *ast.ForStmt) (ast.Node, []ast.Node, error) {
+	results := e.callee.Results()
+	scope, ok := e.info.Scopes[fs.Body]
+	if !ok {
+		return nil, nil, fmt.Errorf("could not find scope")
+	}
+	top := span{fs.Body.Lbrace + 1, fs.Body.Lbrace + 1}
+	errName, assign := e.errVar(scope, top, false)
+	name := results.At(0).Name()
+	if name == "" || name == "_" {
+		name = nameForType(results.At(0).Type())
+	}
+	name = freshName(scope, name, top.Pos(), map[string]bool{errName: true})
+
+	outputStmt, err := e.getFinalOutput(noReturnStr, errName)
+	if err != nil {
+		return nil, nil, err
+	}
+	stmts, err := e.assignWithErr(scope, []ast.Expr{&ast.Ident{Name: name}}, results, errName, assign, e.ce)
+	if err != nil {
+		return nil, nil, err
+	}
+	guard := replaceExpr(&ast.IfStmt{
+		Cond: fs.Cond,
+		Body: &ast.BlockStmt{List: []ast.Stmt{&ast.BranchStmt{Tok: token.BREAK}}},
+	}, e.ce, &ast.Ident{Name: name}).(*ast.IfStmt)
+	guard.Cond = negate(guard.Cond)
+	stmts = append(stmts,
+		&ast.IfStmt{
+			Cond: &ast.BinaryExpr{
+				X:  &ast.Ident{Name: errName},
+				Op: token.NEQ,
+				Y:  &ast.Ident{Name: "nil"},
+			},
+			Body: &ast.BlockStmt{List: outputStmt},
+		},
+		guard)
+
+	text, err := e.formatNodes(stmts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	e.addEdit(edit{pos: top.pos, end: top.end, text: "\n" + text})
+	return span{fs.Cond.Pos(), fs.Cond.End()}, nil, nil
+}
+
+// negate returns the negation of the boolean expression x, e.g. !ok.
+func negate(x ast.Expr) ast.Expr {
+	switch x := x.(type) {
+	case *ast.UnaryExpr:
+		if x.Op == token.NOT {
+			return x.X
+		}
+	case *ast.Ident, *ast.CallExpr, *ast.SelectorExpr, *ast.ParenExpr:
+		return &ast.UnaryExpr{Op: token.NOT, X: x}
+	}
+	return &ast.UnaryExpr{Op: token.NOT, X: &ast.ParenExpr{X: x}}
+}
+
+// formatNodes formats nodes, one per line. Like the replacement of the
+// subject, e.ce keeps its original source text.
+func (e *expansion) formatNodes(nodes ...ast.Node) (string, error) {
+	var ceFmt bytes.Buffer
+	if err := format.Node(&ceFmt, e.fset, e.ce); err != nil {
+		return "", err
+	}
+	ceOrig := string(e.src[e.ce.Pos()-1 : e.ce.End()-1])
+	var lines []string
+	for _, n := range nodes {
+		var buf bytes.Buffer
+		if err := format.Node(&buf, token.NewFileSet(), n); err != nil {
+			return "", fmt.Errorf("formatting replacement: %v", err)
+		}
+		lines = append(lines, strings.Replace(buf.String(), ceFmt.String(), ceOrig, 1))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// addEdit adds ed to e.edits, which are kept in order.
+func (e *expansion) addEdit(ed edit) {
+	idx := len(e.edits)
+	for idx > 0 && e.edits[idx-1].pos > ed.pos {
+		idx--
+	}
+	e.edits = append(e.edits[:idx], append([]edit{ed}, e.edits[idx:]...)...)
+}
@@ -0,0 +1,205 @@
+package main
+
+// This file defines the hoisting of calls nested within other expressions,
+// e.g. process(parse(s)) → v, err := parse(s); if err != nil { … };
+// process(v).
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// innerCallAtCursor returns the innermost call expression within e.ce which
+// contains the cursor and returns an error, e.g. parse(s) in process(parse(s)|,
+// opts) or process(pa|rse(s), opts). It returns nil if there is no such call.
+func (e *expansion) innerCallAtCursor() (*ast.CallExpr, error) {
+	for _, n := range e.path {
+		if n == e.ce {
+			break
+		}
+		if _, ok := n.(*ast.FuncLit); ok {
+			break
+		}
+		ce, ok := n.(*ast.CallExpr)
+		if !ok || e.cursor < ce.Pos() || e.cursor > ce.End() {
+			continue
+		}
+		sig, err := signatureOf(e.info, ce)
+		if err == errUnknownSignature {
+			return nil, err
+		}
+		if err != nil {
+			continue
+		}
+		if _, ok := errorResult(sig); ok {
+			return ce, nil
+		}
+	}
+	return nil, nil
+}
+
+// nestedCall returns whether e.ce returns an error and is nested within
+// another expression, so that its results cannot be checked in place.
+func (e *expansion) nestedCall() bool {
+	if _, ok := errorResult(e.callee); !ok {
+		return false
+	}
+	switch parent := e.parent(e.ce).(type) {
+	case nil, *ast.ExprStmt, *ast.DeferStmt, *ast.GoStmt:
+		// e.ce has no parent in e.path if it was found within the block
+		// under cursor, in which case it is a statement.
+		return false
+	case *ast.AssignStmt:
+		return len(parent.Rhs) != 1
+	case *ast.ValueSpec:
+		return len(parent.Values) != 1
+	case *ast.ReturnStmt:
+		return len(parent.Results) != 1
+	}
+	return true
+}
+
+// enclosingStmt returns the statement before which e.ce can be hoisted, e.g.
+// the if statement for a call within its condition.
+func (e *expansion) enclosingStmt() (ast.Stmt, error) {
+	var stmt ast.Stmt
+	for _, n := range e.path {
+		if stmt == nil {
+			if s, ok := n.(ast.Stmt); ok {
+				if _, ok := s.(*ast.ForStmt); ok {
+					return nil, fmt.Errorf("cannot hoist %s out of the loop condition or post statement", types.ExprString(e.ce))
+				}
+				stmt = s
+			}
+			continue
+		}
+		switch n := n.(type) {
+		case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+			return stmt, nil
+		case *ast.IfStmt:
+			if n.Init == stmt {
+				stmt = n
+				continue
+			}
+		case *ast.SwitchStmt:
+			if n.Init == stmt {
+				stmt = n
+				continue
+			}
+		case *ast.TypeSwitchStmt:
+			if n.Init == stmt || n.Assign == stmt {
+				stmt = n
+				continue
+			}
+		case *ast.ForStmt:
+			if n.Init == stmt {
+				stmt = n
+				continue
+			}
+		}
+		return nil, fmt.Errorf("cannot hoist %s out of the %s", types.ExprString(e.ce), astutil.NodeDescription(n))
+	}
+	return nil, fmt.Errorf("cannot hoist %s: it is not within a statement", types.ExprString(e.ce))
+}
+
+// warnReordering warns if hoisting e.ce before stmt could change the
+// semantics: calls and receives which used to be evaluated before e.ce, and
+// operands of && and || which used to be evaluated conditionally.
+func (e *expansion) warnReordering(stmt ast.Stmt) {
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.CallExpr:
+			if n.End() > e.ce.Pos() {
+				return true
+			}
+			if tv := e.info.Types[unparen(n.Fun)]; tv.IsType() || tv.IsBuiltin() {
+				return true
+			}
+			e.warn("hoisting %s changes the evaluation order: %s used to be evaluated first",
+				types.ExprString(e.ce), types.ExprString(n))
+			return false
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW && n.End() <= e.ce.Pos() {
+				e.warn("hoisting %s changes the evaluation order: %s used to be evaluated first",
+					types.ExprString(e.ce), types.ExprString(n))
+				return false
+			}
+		case *ast.BinaryExpr:
+			if (n.Op == token.LAND || n.Op == token.LOR) && n.Y.Pos() <= e.ce.Pos() && e.ce.End() <= n.Y.End() {
+				e.warn("hoisting %s changes the semantics: it used to be evaluated only depending on %s",
+					types.ExprString(e.ce), types.ExprString(n.X))
+			}
+		}
+		return true
+	})
+}
+
+// expandHoist hoists e.ce out of the expression it is nested in, e.g.
+// process(parse(s), opts) → v, err := parse(s); if err != nil { … };
+// process(v, opts).
+func (e *expansion) expandHoist(noReturnStr string) (ast.Node, []ast.Node, error) {
+	if err := e.checkErrorTypes(); err != nil {
+		return nil, nil, err
+	}
+	results := e.callee.Results()
+	if results.Len() != 2 {
+		return nil, nil, fmt.Errorf("cannot hoist %s: it must return a value and an error, not %d values", types.ExprString(e.ce), results.Len())
+	}
+	if fs := e.loopCond(); fs != nil {
+		return e.expandLoopCond(noReturnStr, fs)
+	}
+	stmt, err := e.enclosingStmt()
+	if err != nil {
+		return nil, nil, err
+	}
+	scope := e.getScope()
+	if scope == nil {
+		return nil, nil, fmt.Errorf("could not find scope")
+	}
+	e.warnReordering(stmt)
+
+	errName, assign := e.errVar(scope, stmt, false)
+	name := results.At(0).Name()
+	if name == "" || name == "_" {
+		name = nameForType(results.At(0).Type())
+	}
+	name = freshName(scope, name, stmt.Pos(), map[string]bool{errName: true})
+
+	outputStmt, err := e.getFinalOutput(noReturnStr, errName)
+	if err != nil {
+		return nil, nil, err
+	}
+	repl, err := e.assignWithErr(scope, []ast.Expr{&ast.Ident{Name: name}}, results, errName, assign, e.ce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return stmt, append(repl,
+		&ast.IfStmt{
+			Cond: &ast.BinaryExpr{
+				X:  &ast.Ident{Name: errName},
+				Op: token.NEQ,
+				Y:  &ast.Ident{Name: "nil"},
+			},
+			Body: &ast.BlockStmt{List: outputStmt},
+		},
+		replaceExpr(stmt, e.ce, &ast.Ident{Name: name})), nil
+}
+
+// replaceExpr returns stmt with the expression old replaced by new. stmt is
+// modified in place.
+func replaceExpr(stmt ast.Stmt, old, new ast.Expr) ast.Stmt {
+	return astutil.Apply(stmt, func(c *astutil.Cursor) bool {
+		if c.Node() == old {
+			c.Replace(new)
+			return false
+		}
+		return true
+	}, nil).(ast.Stmt)
+}
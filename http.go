@@ -0,0 +1,79 @@
+package main
+
+// This file defines how errors are handled within HTTP handlers, which report
+// them to the client.
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// responseWriter returns the net/http.ResponseWriter parameter of the caller,
+// if any.
+func (e *expansion) responseWriter() *types.Var {
+	params := e.callerSig.Params()
+	for idx := 0; idx < params.Len(); idx++ {
+		p := params.At(idx)
+		if p.Name() == "" || p.Name() == "_" {
+			continue
+		}
+		if _, ok := types.Unalias(p.Type()).(*types.Pointer); ok {
+			continue
+		}
+		if isNamed(p.Type(), "net/http", "ResponseWriter") {
+			return p
+		}
+	}
+	return nil
+}
+
+// statusCode returns an expression for the HTTP status code code, referring
+// to the net/http constant (e.g. http.StatusInternalServerError) if there is
+// one.
+func (e *expansion) statusCode(code int) ast.Expr {
+	if e.pkg != nil {
+		for _, imp := range e.pkg.Imports() {
+			if imp.Path() != "net/http" {
+				continue
+			}
+			scope := imp.Scope()
+			for _, name := range scope.Names() {
+				c, ok := scope.Lookup(name).(*types.Const)
+				if !ok || !strings.HasPrefix(name, "Status") {
+					continue
+				}
+				if v, ok := constant.Int64Val(c.Val()); ok && v == int64(code) {
+					return e.qualify("net/http", name)
+				}
+			}
+		}
+	}
+	return &ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(code)}
+}
+
+// httpError returns a statement reporting errExpr to the client using the
+// ResponseWriter w, e.g. http.Error(w, err.Error(),
+// http.StatusInternalServerError).
+func (e *expansion) httpError(w *types.Var, errExpr ast.Expr) ast.Stmt {
+	msg := ast.Expr(&ast.CallExpr{
+		Fun: &ast.SelectorExpr{X: errExpr, Sel: &ast.Ident{Name: "Error"}},
+	})
+	if ce, ok := errExpr.(*ast.CallExpr); ok && e.isPkgFunc(ce.Fun, "fmt", "Errorf") {
+		if lit, ok := ce.Args[0].(*ast.BasicLit); ok && !strings.Contains(lit.Value, "%w") {
+			// e.g. fmt.Errorf("key %q not found", k) → fmt.Sprintf("key %q not found", k)
+			msg = &ast.CallExpr{Fun: e.qualify("fmt", "Sprintf"), Args: ce.Args}
+		}
+	}
+	return &ast.ExprStmt{X: &ast.CallExpr{
+		Fun: e.qualify("net/http", "Error"),
+		Args: []ast.Expr{
+			&ast.Ident{Name: w.Name()},
+			msg,
+			e.statusCode(*httpStatus),
+		},
+	}}
+}
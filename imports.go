@@ -0,0 +1,107 @@
+package main
+
+// This file defines utilities for referring to other packages from the file
+// under cursor.
+
+import (
+	"go/ast"
+	"go/types"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// importName returns the name under which the file under cursor refers to the
+// package with the specified import path and package name. If the file does not
+// import the package yet, the import is recorded in e.imports so that it will
+// be added.
+func (e *expansion) importName(importPath, pkgName string) string {
+	if name, ok := e.importedAs(importPath, pkgName); ok {
+		return name
+	}
+	e.imports = append(e.imports, importPath)
+	return pkgName
+}
+
+// importedAs returns the name under which the file under cursor refers to the
+// package with the specified import path and package name, and whether the
+// file imports it (or it is recorded in e.imports).
+func (e *expansion) importedAs(importPath, pkgName string) (string, bool) {
+	for _, imp := range e.file.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil || p != importPath {
+			continue
+		}
+		if imp.Name == nil {
+			return pkgName, true
+		}
+		if imp.Name.Name == "_" {
+			continue // not usable, look for another import
+		}
+		return imp.Name.Name, true
+	}
+	for _, p := range e.imports {
+		if p == importPath {
+			return pkgName, true
+		}
+	}
+	return "", false
+}
+
+// imported returns whether the file under cursor imports importPath.
+func (e *expansion) imported(importPath string) bool {
+	for _, imp := range e.file.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err == nil && p == importPath && (imp.Name == nil || imp.Name.Name != "_") {
+			return true
+		}
+	}
+	return false
+}
+
+// qualify returns an expression referring to the package-level identifier
+// name in the package with the specified import path, e.g. fmt.Errorf.
+func (e *expansion) qualify(importPath, name string) ast.Expr {
+	pkgName := e.importName(importPath, path.Base(importPath))
+	if pkgName == "." {
+		return &ast.Ident{Name: name}
+	}
+	return &ast.SelectorExpr{
+		X:   &ast.Ident{Name: pkgName},
+		Sel: &ast.Ident{Name: name},
+	}
+}
+
+// unvendor returns the import path under which a package with the specified
+// (possibly vendored) package path is imported, e.g. "lib" for
+// "multipkg/vendor/lib".
+func unvendor(pkgPath string) string {
+	if idx := strings.LastIndex(pkgPath, "/vendor/"); idx > -1 {
+		return pkgPath[idx+len("/vendor/"):]
+	}
+	return strings.TrimPrefix(pkgPath, "vendor/")
+}
+
+// qualifier is a types.Qualifier which refers to packages by the names under
+// which the file under cursor imports them, recording missing imports. It is
+// only to be used for types which end up in the generated code.
+func (e *expansion) qualifier(pkg *types.Package) string {
+	if pkg == e.pkg {
+		return ""
+	}
+	name := e.importName(unvendor(pkg.Path()), pkg.Name())
+	if name == "." {
+		return ""
+	}
+	return name
+}
+
+// messageQualifier is a types.Qualifier for types mentioned in warnings and
+// errors. Unlike qualifier, it does not record imports, as such types do not
+// end up in the generated code.
+func (e *expansion) messageQualifier(pkg *types.Package) string {
+	if pkg == e.pkg {
+		return ""
+	}
+	return pkg.Name()
+}
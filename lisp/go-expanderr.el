@@ -10,6 +10,13 @@
   :type 'boolean
   :group 'expanderr)
 
+(defcustom go-expanderr-no-error-callback nil
+  "Statement passed as -no-error-callback, e.g. \"log.Fatal(err)\".
+If nil, expanderr derives the error handling in functions which do not return
+an error from the context (goroutine, test, HTTP handler, main)."
+  :type '(choice (const :tag "Derived from context" nil) string)
+  :group 'expanderr)
+
 (defun go-expanderr ()
   "Expand the Call Expression before/under the cursor to check errors."
   (interactive)
@@ -30,17 +37,22 @@
           (with-current-buffer patchbuf
             (erase-buffer))
 
-	  (save-buffer)
           (setq expanderr-command go-expanderr-command)
-          (setq our-expanderr-args (list "-w" tmpfile "-no-error-callback" "log.Fatal(err)"
-					 (concat
-					  (file-truename buffer-file-name)
-					  (format ":#%d" (position-bytes (point))))))
+          ;; The buffer contents are passed on stdin, so unsaved changes are
+          ;; expanded without saving the buffer first.
+          (setq our-expanderr-args (append
+				    (list "-w" tmpfile)
+				    (if go-expanderr-no-error-callback
+					(list "-no-error-callback" go-expanderr-no-error-callback))
+				    (list "-stdin"
+					  (concat
+					   (file-truename buffer-file-name)
+					   (format ":#%d" (position-bytes (point)))))))
           (message "Calling expanderr: %s %s" expanderr-command our-expanderr-args)
           ;; We're using errbuf for the mixed stdout and stderr output. This
           ;; is not an issue because expanderr -w does not produce any stdout
           ;; output in case of success.
-          (if (zerop (apply #'call-process expanderr-command nil errbuf nil our-expanderr-args))
+          (if (zerop (apply #'call-process-region (point-min) (point-max) expanderr-command nil errbuf nil our-expanderr-args))
               (progn
                 (if (zerop (call-process-region (point-min) (point-max) "diff" nil patchbuf nil "-n" "-" tmpfile))
                     (message "Buffer is already expanded")
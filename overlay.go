@@ -0,0 +1,95 @@
+package main
+
+// This file defines overlays: unsaved contents of files, which are used instead
+// of the contents on disk so that editors can expand without saving first.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// overlay maps absolute file names to their unsaved contents. A nil value
+// marks a file as deleted.
+var overlay = make(map[string][]byte)
+
+// readOverlay adds the replacements of fn, a JSON file in the format of “go
+// build -overlay”, to overlay, e.g. {"Replace": {"/src/foo.go":
+// "/tmp/foo.go"}}. An empty replacement marks the file as deleted.
+func readOverlay(fn string) error {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	var cfg struct {
+		Replace map[string]string
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return fmt.Errorf("parsing overlay %s: %v", fn, err)
+	}
+	for path, replacement := range cfg.Replace {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if replacement == "" {
+			overlay[abs] = nil
+			continue
+		}
+		contents, err := ioutil.ReadFile(replacement)
+		if err != nil {
+			return err
+		}
+		overlay[abs] = contents
+	}
+	return nil
+}
+
+// readFile returns the contents of filename, preferring its overlay.
+func readFile(filename string) ([]byte, error) {
+	if abs, err := filepath.Abs(filename); err == nil {
+		if b, ok := overlay[abs]; ok {
+			if b == nil {
+				return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
+			}
+			return b, nil
+		}
+	}
+	return ioutil.ReadFile(filename)
+}
+
+// overlayNames returns the names of the files in dir which only exist in the
+// overlay, i.e. which are not among names.
+func overlayNames(dir string, names []string) []string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	onDisk := make(map[string]bool)
+	for _, n := range names {
+		onDisk[n] = true
+	}
+	var result []string
+	for path, contents := range overlay {
+		if contents != nil && filepath.Dir(path) == abs && !onDisk[filepath.Base(path)] {
+			result = append(result, filepath.Base(path))
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// openFile opens path, preferring its overlay. It is used as
+// build.Context.OpenFile, so that imported packages see unsaved contents, too.
+func openFile(path string) (io.ReadCloser, error) {
+	b, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
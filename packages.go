@@ -0,0 +1,144 @@
+package main
+
+// This file defines loading the package under cursor using
+// golang.org/x/tools/go/packages, which resolves imports like the go command
+// does: honoring go.mod and go.work files, replace directives and vendoring.
+// GOPATH workspaces are loaded using go/build instead.
+
+import (
+	"fmt"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// moduleDir returns the directory of the go.mod or go.work file governing
+// dir, or "" if dir is to be loaded in GOPATH mode: with GO111MODULE=off, or
+// if there is no such file within the GOPATH workspace containing dir.
+func moduleDir(buildctx *build.Context, dir string) string {
+	if os.Getenv("GO111MODULE") == "off" {
+		return ""
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	srcDirs := make(map[string]bool)
+	for _, root := range filepath.SplitList(buildctx.GOPATH) {
+		srcDirs[filepath.Join(root, "src")] = true
+	}
+	for {
+		if srcDirs[dir] {
+			return "" // do not leave the GOPATH workspace
+		}
+		for _, name := range []string{"go.mod", "go.work"} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return dir
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadPackage loads the package containing filename along with its
+// dependencies. It returns an importer for the packages imported by the
+// package and the names of the package’s files, honoring build constraints.
+func loadPackage(buildctx *build.Context, filename string) (types.Importer, []string, error) {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg := &packages.Config{
+		Mode:    packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps | packages.NeedTypes,
+		Dir:     filepath.Dir(filename),
+		Env:     append(os.Environ(), "GOOS="+buildctx.GOOS, "GOARCH="+buildctx.GOARCH),
+		Tests:   strings.HasSuffix(filename, "_test.go"),
+		Overlay: make(map[string][]byte),
+	}
+	for path, contents := range overlay {
+		if contents != nil {
+			cfg.Overlay[path] = contents
+		}
+	}
+	if len(buildctx.BuildTags) > 0 {
+		cfg.BuildFlags = []string{"-tags=" + strings.Join(buildctx.BuildTags, ",")}
+	}
+	pkgs, err := packages.Load(cfg, "file="+filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, pkg := range pkgs {
+		for _, fn := range pkg.GoFiles {
+			if fn == filename {
+				return &packagesImporter{pkg: pkg, fallback: defaultImporter()}, pkg.GoFiles, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("no package contains %s", filename)
+}
+
+// packagesImporter imports the packages imported by pkg, as loaded by
+// go/packages. Other packages are imported using fallback.
+type packagesImporter struct {
+	pkg      *packages.Package
+	fallback types.Importer
+}
+
+func (pi *packagesImporter) Import(path string) (*types.Package, error) {
+	if imp, ok := pi.pkg.Imports[path]; ok && imp.Types != nil {
+		return imp.Types, nil
+	}
+	return pi.fallback.Import(path)
+}
+
+// packageFiles returns the names of the files in the directory of filename
+// (including files which only exist in the overlay) which belong to the same
+// package as filename (named pkgName): files which satisfy the build
+// constraints of buildctx and declare package pkgName. Test files are only
+// included if filename is a test file.
+func packageFiles(buildctx *build.Context, filename, pkgName string) ([]string, error) {
+	dir := filepath.Dir(filename)
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	names, err := d.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	names = append(names, overlayNames(dir, names)...)
+	inTest := strings.HasSuffix(filename, "_test.go")
+	var result []string
+	for _, n := range names {
+		if strings.HasPrefix(n, "expanderr") {
+			continue // skip expanderr temp file when working in /tmp
+		}
+		if !strings.HasSuffix(n, ".go") || (strings.HasSuffix(n, "_test.go") && !inTest) {
+			continue
+		}
+		if ok, err := buildctx.MatchFile(dir, n); err != nil || !ok {
+			continue
+		}
+		src, err := readFile(filepath.Join(dir, n))
+		if err != nil {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), n, src, parser.PackageClauseOnly)
+		if err != nil || f.Name.Name != pkgName {
+			continue // e.g. the external test package foo_test
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
@@ -7,12 +7,15 @@ package main
 // This file defines utilities for working with file positions.
 
 import (
+	"bytes"
 	"fmt"
 	"go/token"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // parseOctothorpDecimal returns the numeric value if s matches "#%d",
@@ -30,37 +33,127 @@ func parseOctothorpDecimal(s string) int {
 // file:start,end" where pos, start, end match #%d and represent byte
 // offsets, and returns its components.
 //
-// (Numbers without a '#' prefix are reserved for future use,
-// e.g. to indicate line/column positions.)
+// Positions may also be given as "file:line:column" or
+// "file:line:column-line:column" (1-based). Columns count bytes, or UTF-16
+// code units (as sent by LSP clients) if the -utf16 flag is set. These are
+// converted to byte offsets using the contents of the file.
+//
+// Queries naming a function or method (e.g. "file:(*T).Method#2") are
+// resolved by logic using type information; see resolveSymbol.
 //
 func parsePos(pos string) (filename string, startOffset, endOffset int, err error) {
+	filename, offset, err := splitPos(pos)
+	if err != nil {
+		return
+	}
+	startOffset = -1
+	endOffset = -1
+	if strings.HasPrefix(offset, "#") {
+		if comma := strings.Index(offset, ","); comma < 0 {
+			// e.g. "foo.go:#123"
+			startOffset = parseOctothorpDecimal(offset)
+			endOffset = startOffset
+		} else {
+			// e.g. "foo.go:#123,#456"
+			startOffset = parseOctothorpDecimal(offset[:comma])
+			endOffset = parseOctothorpDecimal(offset[comma+1:])
+		}
+	} else if lineColumnPos.MatchString(pos) {
+		// e.g. "foo.go:12:7" or "foo.go:12:7-14:2"
+		var b []byte
+		if b, err = readFile(filename); err != nil {
+			return
+		}
+		start, end := offset, offset
+		if dash := strings.Index(offset, "-"); dash >= 0 {
+			start, end = offset[:dash], offset[dash+1:]
+		}
+		if startOffset, err = lineColumnOffset(b, start); err != nil {
+			return
+		}
+		if endOffset, err = lineColumnOffset(b, end); err != nil {
+			return
+		}
+	} else {
+		err = fmt.Errorf("bad position syntax %q", pos)
+		return
+	}
+	if startOffset < 0 || endOffset < startOffset {
+		err = fmt.Errorf("invalid offset %q in query position", offset)
+		return
+	}
+	return
+}
+
+// lineColumnPos matches the line:column forms of positions.
+var lineColumnPos = regexp.MustCompile(`:\d+:\d+(-\d+:\d+)?$`)
+
+// splitPos splits pos into the file name and the position within the file,
+// e.g. "foo.go:#123" into "foo.go" and "#123", or "foo.go:12:7" into "foo.go"
+// and "12:7". It does not access the file.
+func splitPos(pos string) (filename, offset string, err error) {
 	if pos == "" {
 		err = fmt.Errorf("no source position specified")
 		return
 	}
-
 	colon := strings.LastIndex(pos, ":")
+	if loc := lineColumnPos.FindStringIndex(pos); loc != nil {
+		colon = loc[0]
+	}
 	if colon < 0 {
 		err = fmt.Errorf("bad position syntax %q", pos)
 		return
 	}
-	filename, offset := pos[:colon], pos[colon+1:]
-	startOffset = -1
-	endOffset = -1
-	if comma := strings.Index(offset, ","); comma < 0 {
-		// e.g. "foo.go:#123"
-		startOffset = parseOctothorpDecimal(offset)
-		endOffset = startOffset
-	} else {
-		// e.g. "foo.go:#123,#456"
-		startOffset = parseOctothorpDecimal(offset[:comma])
-		endOffset = parseOctothorpDecimal(offset[comma+1:])
+	return pos[:colon], pos[colon+1:], nil
+}
+
+// lineColumnOffset returns the byte offset within b of lineColumn, which
+// must be of the form "line:column".
+func lineColumnOffset(b []byte, lineColumn string) (int, error) {
+	colon := strings.Index(lineColumn, ":")
+	line, err := strconv.Atoi(lineColumn[:colon])
+	if err != nil {
+		return -1, err
 	}
-	if startOffset < 1 || endOffset < startOffset {
-		err = fmt.Errorf("invalid offset %q in query position", offset)
-		return
+	column, err := strconv.Atoi(lineColumn[colon+1:])
+	if err != nil {
+		return -1, err
 	}
-	return
+	if line < 1 || column < 1 {
+		return -1, fmt.Errorf("invalid position %q: lines and columns start at 1", lineColumn)
+	}
+
+	offset := 0
+	for l := 1; l < line; l++ {
+		nl := bytes.IndexByte(b[offset:], '\n')
+		if nl < 0 {
+			return -1, fmt.Errorf("line %d is beyond end of file", line)
+		}
+		offset += nl + 1
+	}
+	eol := len(b)
+	if nl := bytes.IndexByte(b[offset:], '\n'); nl >= 0 {
+		eol = offset + nl
+	}
+
+	if !*utf16Flag {
+		if offset+column-1 > eol {
+			return -1, fmt.Errorf("column %d is beyond end of line %d", column, line)
+		}
+		return offset + column - 1, nil
+	}
+	for units := column - 1; units > 0; {
+		if offset >= eol {
+			return -1, fmt.Errorf("column %d is beyond end of line %d", column, line)
+		}
+		r, size := utf8.DecodeRune(b[offset:])
+		offset += size
+		units--
+		if r > 0xffff {
+			units-- // encoded as a surrogate pair
+		}
+	}
+	return offset, nil
 }
 
 // fileOffsetToPos translates the specified file-relative byte offsets
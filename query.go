@@ -0,0 +1,140 @@
+package main
+
+// This file defines symbol-based queries, which name the function or method
+// containing the call to expand instead of its position, e.g.
+// foo.go:(*Server).Start#3 for the third call with an unchecked error within
+// the Start method of *Server.
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/types"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// symbolQuery matches queries of the form “Func”, “Type.Method”,
+// “(*Type).Method” or any of those followed by “#N”.
+var symbolQuery = regexp.MustCompile(`^(?:\(?\*?([\p{L}_][\p{L}\p{N}_]*)\)?\.)?([\p{L}_][\p{L}\p{N}_]*)(?:#(\d+))?$`)
+
+// resolveSymbol returns the byte offset of the end of the call selected by
+// query within e.file, i.e. where the cursor would be placed to expand it.
+// The call is the Nth (default: first) call with an unchecked error result
+// within the named function or method, counted in source order.
+func (e *expansion) resolveSymbol(buildctx *build.Context, filename, query string) (int, error) {
+	m := symbolQuery.FindStringSubmatch(query)
+	recv, name, ordinal := m[1], m[2], 1
+	if m[3] != "" {
+		var err error
+		if ordinal, err = strconv.Atoi(m[3]); err != nil {
+			return -1, err
+		}
+		if ordinal < 1 {
+			return -1, fmt.Errorf("invalid query %q: calls are counted starting at 1", query)
+		}
+	}
+
+	var decl *ast.FuncDecl
+	for _, d := range e.file.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Name.Name == name && receiverName(fd) == recv {
+			decl = fd
+			break
+		}
+	}
+	if decl == nil || decl.Body == nil {
+		return -1, fmt.Errorf("no function %s with a body found in %s", strings.SplitN(query, "#", 2)[0], filename)
+	}
+
+	files, err := e.parsePackage(buildctx, filename)
+	if err != nil {
+		return -1, err
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+	}
+	imp := e.importer
+	if imp == nil {
+		imp = defaultImporter()
+	}
+	conf := types.Config{
+		Importer: imp,
+		Error:    func(error) {}, // keep going on errors
+	}
+	conf.Check(e.file.Name.Name, e.fset, files, info)
+
+	var calls []*ast.CallExpr
+	astutil.Apply(decl.Body, func(c *astutil.Cursor) bool {
+		if ce, ok := c.Node().(*ast.CallExpr); ok && uncheckedCall(info, ce, c.Parent()) {
+			calls = append(calls, ce)
+		}
+		return true
+	}, nil)
+	if ordinal > len(calls) {
+		return -1, fmt.Errorf("%s contains %d calls with unchecked errors, not %d", decl.Name.Name, len(calls), ordinal)
+	}
+	return e.fset.Position(calls[ordinal-1].End()).Offset, nil
+}
+
+// receiverName returns the name of the receiver’s type if fd is a method,
+// e.g. Server for func (s *Server) Start(), or "" if fd is a function.
+func receiverName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return ""
+	}
+	typ := unparen(fd.Recv.List[0].Type)
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = unparen(star.X)
+	}
+	switch t := typ.(type) {
+	case *ast.IndexExpr: // e.g. func (l *List[T]) Push(v T)
+		typ = t.X
+	case *ast.IndexListExpr:
+		typ = t.X
+	}
+	if id, ok := typ.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+// uncheckedCall returns whether ce returns an error which is discarded: ce is
+// a statement (e.g. os.Remove(p) or defer f.Close()), or its error is assigned
+// to “_” or not assigned at all (e.g. n, _ := w.Write(p)). parent is the
+// parent node of ce.
+func uncheckedCall(info *types.Info, ce *ast.CallExpr, parent ast.Node) bool {
+	sig, err := signatureOf(info, ce)
+	if err != nil {
+		return false
+	}
+	if _, ok := errorResult(sig); !ok {
+		return false
+	}
+	var lhs []ast.Expr
+	switch p := parent.(type) {
+	case *ast.ExprStmt, *ast.DeferStmt, *ast.GoStmt:
+		return true
+	case *ast.AssignStmt:
+		if len(p.Rhs) != 1 || p.Rhs[0] != ce {
+			return false
+		}
+		lhs = p.Lhs
+	case *ast.ValueSpec:
+		if len(p.Values) != 1 || p.Values[0] != ce {
+			return false
+		}
+		for _, id := range p.Names {
+			lhs = append(lhs, id)
+		}
+	default:
+		return false
+	}
+	if len(lhs) < sig.Results().Len() {
+		return true // e.g. n := w.Write(p)
+	}
+	id, ok := lhs[len(lhs)-1].(*ast.Ident)
+	return ok && id.Name == "_"
+}
@@ -0,0 +1,171 @@
+package main
+
+// This file defines how the error variable of an expansion is chosen so that
+// it neither redeclares nor shadows variables in scope in ways which would
+// change the behavior of the surrounding code.
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+)
+
+// errVar returns the name of the error variable for the error check of n and
+// whether an existing variable of that name must be assigned (“=”) instead of
+// declaring a new one (“:=”). scoped is whether a new variable would only be
+// declared within the error check, e.g. if err := f(); err != nil { … }.
+//
+// The named error result is always assigned. Another err variable is assigned
+// if a deferred function inspects it, as shadowing it would hide the error
+// from that function. A different name (e.g. “err2”) is used if declaring err
+// would redeclare a variable of another type or shadow an err which is
+// referred to after n.
+func (e *expansion) errVar(scope *types.Scope, n ast.Node, scoped bool) (string, bool) {
+	if named := e.namedErrorResult(); named != nil {
+		return named.Name(), true
+	}
+	const name = "err"
+	if scope == nil {
+		return name, false
+	}
+	obj := lookup(scope, name, n.Pos())
+	if obj == nil {
+		return name, false
+	}
+	if v := e.errVarInScope(scope, name, n.Pos()); v != nil && v.Parent() != e.pkg.Scope() && e.inspectedByDefer(v) {
+		e.warn("assigning to %s declared at %s instead of shadowing it: a deferred function inspects it",
+			name, e.fset.Position(v.Pos()))
+		return name, true
+	}
+	if scoped {
+		return name, false
+	}
+	if obj.Parent() == scope && e.errVarInScope(scope, name, n.Pos()) == nil {
+		fresh := freshName(scope, name, n.Pos(), nil)
+		e.warn("naming the error %s: %s is already declared as %s at %s",
+			fresh, name, types.TypeString(obj.Type(), e.messageQualifier), e.fset.Position(obj.Pos()))
+		return fresh, false
+	}
+	if obj.Parent() != scope && e.usedWithin(obj, n.End(), scope.End()) {
+		fresh := freshName(scope, name, n.Pos(), nil)
+		e.warn("naming the error %s: declaring %s would shadow the %s declared at %s, which is referred to afterwards",
+			fresh, name, name, e.fset.Position(obj.Pos()))
+		return fresh, false
+	}
+	return name, false
+}
+
+// errVarInScope returns the variable named name which is in scope at pos if
+// the callee’s error can be assigned to it, nil otherwise.
+func (e *expansion) errVarInScope(scope *types.Scope, name string, pos token.Pos) *types.Var {
+	v, ok := lookup(scope, name, pos).(*types.Var)
+	if !ok {
+		return nil
+	}
+	calleeErr, ok := errorResult(e.callee)
+	if !ok {
+		calleeErr = types.Universe.Lookup("error").Type()
+	}
+	if !types.AssignableTo(calleeErr, v.Type()) {
+		return nil
+	}
+	return v
+}
+
+// inspectedByDefer returns whether v is referred to by a deferred function
+// literal or its address is passed to a deferred call, e.g. defer
+// handle(&err).
+func (e *expansion) inspectedByDefer(v *types.Var) bool {
+	found := false
+	ast.Inspect(e.file, func(n ast.Node) bool {
+		ds, ok := n.(*ast.DeferStmt)
+		if !ok {
+			return !found
+		}
+		ast.Inspect(ds.Call, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				found = found || e.usedWithin(v, n.Pos(), n.End())
+				return false
+			case *ast.UnaryExpr:
+				if id, ok := unparen(n.X).(*ast.Ident); ok && n.Op == token.AND && e.info.Uses[id] == v {
+					found = true
+				}
+			}
+			return !found
+		})
+		return !found
+	})
+	return found
+}
+
+// usedWithin returns whether obj is referred to between pos and end.
+func (e *expansion) usedWithin(obj types.Object, pos, end token.Pos) bool {
+	for id, used := range e.info.Uses {
+		if used == obj && id.Pos() >= pos && id.End() <= end {
+			return true
+		}
+	}
+	return false
+}
+
+// declaredIn returns whether all identifiers in lhs (apart from “_”) are
+// already declared in scope at pos, so that assigning them requires “=”.
+func declaredIn(scope *types.Scope, lhs []ast.Expr, pos token.Pos) bool {
+	for _, expr := range lhs {
+		id, ok := expr.(*ast.Ident)
+		if !ok {
+			return false
+		}
+		if id.Name == "_" {
+			continue
+		}
+		if obj := lookup(scope, id.Name, pos); obj == nil || obj.Parent() != scope {
+			return false
+		}
+	}
+	return true
+}
+
+// assignWithErr returns the statements assigning the results of call to lhs
+// and the error variable errName, e.g. v, err := f(). If errName must be
+// assigned and is declared in an enclosing scope, the variables in lhs are
+// declared first to not shadow errName, e.g. var v T; v, err = f().
+func (e *expansion) assignWithErr(scope *types.Scope, lhs []ast.Expr, results *types.Tuple, errName string, assign bool, call ast.Expr) ([]ast.Node, error) {
+	as := &ast.AssignStmt{
+		Lhs: append(lhs[:len(lhs):len(lhs)], &ast.Ident{Name: errName}),
+		Tok: token.DEFINE,
+		Rhs: []ast.Expr{call},
+	}
+	if !assign {
+		return []ast.Node{as}, nil
+	}
+	if obj := lookup(scope, errName, e.path[0].Pos()); obj != nil && obj.Parent() == scope {
+		return []ast.Node{as}, nil // “:=” assigns errName, as it is declared in the same scope
+	}
+	var repl []ast.Node
+	for idx, expr := range lhs {
+		id, ok := expr.(*ast.Ident)
+		if !ok || id.Name == "_" {
+			continue
+		}
+		typeExpr, err := parser.ParseExpr(types.TypeString(results.At(idx).Type(), e.qualifier))
+		if err != nil {
+			return nil, err
+		}
+		repl = append(repl, &ast.DeclStmt{
+			Decl: &ast.GenDecl{
+				Tok: token.VAR,
+				Specs: []ast.Spec{
+					&ast.ValueSpec{
+						Names: []*ast.Ident{{Name: id.Name}},
+						Type:  typeExpr,
+					},
+				},
+			},
+		})
+	}
+	as.Tok = token.ASSIGN
+	return append(repl, as), nil
+}
@@ -0,0 +1,11 @@
+package main
+
+import "os"
+
+func run() error {
+	fn := func() error {
+		os.Create("x")
+		return nil
+	}
+	return fn()
+}
@@ -0,0 +1,13 @@
+package main
+
+import "os"
+
+func run() error {
+	fn := func() error {
+		if _, err := os.Create("x"); err != nil {
+			return err
+		}
+		return nil
+	}
+	return fn()
+}
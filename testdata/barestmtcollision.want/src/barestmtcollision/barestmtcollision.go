@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func parse(s string) (value int, rest string, err error) {
+	_, err = fmt.Sscanf(s, "%d %s", &value, &rest)
+	return value, rest, err
+}
+
+func double(s string) (int, error) {
+	parse(s)
+	return value * 2, nil
+}
+
+func write(path string, f int) error {
+	f2, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f2.Close()
+	os.ReadFile(path)
+	return nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func parse(s string) (value int, rest string, err error) {
+	_, err = fmt.Sscanf(s, "%d %s", &value, &rest)
+	return value, rest, err
+}
+
+func double(s string) (int, error) {
+	parse(s)
+	return value * 2, nil
+}
+
+func write(path string, f int) error {
+	os.Create(path)
+	defer f2.Close()
+	if _, err := os.ReadFile(path); err != nil {
+		return err
+	}
+	return nil
+}
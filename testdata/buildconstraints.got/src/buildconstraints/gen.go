@@ -0,0 +1,7 @@
+//go:build ignore
+
+package main
+
+func load(name string) string {
+	return name
+}
@@ -0,0 +1,6 @@
+package main
+
+func run() (*Config, error) {
+	cfg := load("app.conf")
+	return cfg, nil
+}
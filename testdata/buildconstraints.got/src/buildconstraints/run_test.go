@@ -0,0 +1,5 @@
+package main_test
+
+func load() int {
+	return 0
+}
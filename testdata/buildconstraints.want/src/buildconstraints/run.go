@@ -0,0 +1,9 @@
+package main
+
+func run() (*Config, error) {
+	cfg, err := load("app.conf")
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
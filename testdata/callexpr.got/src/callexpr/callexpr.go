@@ -0,0 +1,13 @@
+package main
+
+type name string
+
+func conversion(x string) (int, error) {
+	n := name(x)
+	return len(n), nil
+}
+
+func builtin(errc chan error) (int, error) {
+	close(errc)
+	return 0, nil
+}
@@ -0,0 +1,6 @@
+package main
+
+func element(hooks []func() error, i int) (int, error) {
+	hooks[i]()
+	return 0, nil
+}
@@ -0,0 +1,8 @@
+package main
+
+func element(hooks []func() error, i int) (int, error) {
+	if err := hooks[i](); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
@@ -0,0 +1,10 @@
+package main
+
+type server struct {
+	handler func(string) error
+}
+
+func field(s *server, x string) (int, error) {
+	s.handler(x)
+	return 0, nil
+}
@@ -0,0 +1,12 @@
+package main
+
+type server struct {
+	handler func(string) error
+}
+
+func field(s *server, x string) (int, error) {
+	if err := s.handler(x); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
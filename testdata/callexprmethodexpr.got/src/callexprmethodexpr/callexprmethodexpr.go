@@ -0,0 +1,12 @@
+package main
+
+import "os"
+
+type closer struct{ f *os.File }
+
+func (c *closer) Close() error { return c.f.Close() }
+
+func methodExpr(c *closer) (int, error) {
+	(*closer).Close(c)
+	return 0, nil
+}
@@ -0,0 +1,14 @@
+package main
+
+import "os"
+
+type closer struct{ f *os.File }
+
+func (c *closer) Close() error { return c.f.Close() }
+
+func methodExpr(c *closer) (int, error) {
+	if err := (*closer).Close(c); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
@@ -0,0 +1,10 @@
+package main
+
+import "os"
+
+func factory() func(string) error { return os.Remove }
+
+func returned(x string) (int, error) {
+	factory()(x)
+	return 0, nil
+}
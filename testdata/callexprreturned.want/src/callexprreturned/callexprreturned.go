@@ -0,0 +1,12 @@
+package main
+
+import "os"
+
+func factory() func(string) error { return os.Remove }
+
+func returned(x string) (int, error) {
+	if err := factory()(x); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
@@ -0,0 +1,24 @@
+package main
+
+type Client struct{}
+
+type Session struct{}
+
+type Item struct{}
+
+func (c *Client) Connect(addr string) (*Session, error) {
+	return &Session{}, nil
+}
+
+func (s *Session) Login(user string) (*Session, error) {
+	return s, nil
+}
+
+func (s *Session) Fetch(id int) (*Item, error) {
+	return &Item{}, nil
+}
+
+func load(client *Client, addr, user string, id int) (*Item, error) {
+	item := client.Connect(addr).Login(user).Fetch(id)
+	return item, nil
+}
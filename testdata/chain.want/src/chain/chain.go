@@ -0,0 +1,35 @@
+package main
+
+type Client struct{}
+
+type Session struct{}
+
+type Item struct{}
+
+func (c *Client) Connect(addr string) (*Session, error) {
+	return &Session{}, nil
+}
+
+func (s *Session) Login(user string) (*Session, error) {
+	return s, nil
+}
+
+func (s *Session) Fetch(id int) (*Item, error) {
+	return &Item{}, nil
+}
+
+func load(client *Client, addr, user string, id int) (*Item, error) {
+	s, err := client.Connect(addr)
+	if err != nil {
+		return nil, err
+	}
+	s2, err := s.Login(user)
+	if err != nil {
+		return nil, err
+	}
+	item, err := s2.Fetch(id)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func lookup(m map[string]int, k string) (int, error) {
+	v := m[k]
+	return v, nil
+}
+
+func assert(x interface{}) (string, error) {
+	s, ok := x.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected type %T, want string", x)
+	}
+	return s, nil
+}
+
+func recv(results chan int) (int, error) {
+	v := <-results
+	return v, nil
+}
+
+func env() error {
+	os.LookupEnv("HOME")
+	return nil
+}
@@ -0,0 +1,10 @@
+package main
+
+func set(m map[string]int, k string, v int) error {
+	m[k] = v
+	return nil
+}
+
+func main() {
+	set(map[string]int{}, "a", 1)
+}
@@ -0,0 +1,23 @@
+package main
+
+import "os"
+
+func lookup(m map[string]int, k string) (int, error) {
+	v := m[k]
+	return v, nil
+}
+
+func assert(x interface{}) (string, error) {
+	s := x.(string)
+	return s, nil
+}
+
+func recv(results chan int) (int, error) {
+	v := <-results
+	return v, nil
+}
+
+func env() error {
+	os.LookupEnv("HOME")
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func lookup(m map[string]int, k string) (int, error) {
+	v := m[k]
+	return v, nil
+}
+
+func assert(x interface{}) (string, error) {
+	s := x.(string)
+	return s, nil
+}
+
+func recv(results chan int) (int, error) {
+	v := <-results
+	return v, nil
+}
+
+func env() error {
+	if _, ok := os.LookupEnv("HOME"); !ok {
+		return fmt.Errorf("lookup env %q failed", "HOME")
+	}
+	return nil
+}
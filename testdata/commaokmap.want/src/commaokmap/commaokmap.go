@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func lookup(m map[string]int, k string) (int, error) {
+	v, ok := m[k]
+	if !ok {
+		return 0, fmt.Errorf("key %q not found", k)
+	}
+	return v, nil
+}
+
+func assert(x interface{}) (string, error) {
+	s := x.(string)
+	return s, nil
+}
+
+func recv(results chan int) (int, error) {
+	v := <-results
+	return v, nil
+}
+
+func env() error {
+	os.LookupEnv("HOME")
+	return nil
+}
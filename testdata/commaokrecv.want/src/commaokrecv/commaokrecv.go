@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func lookup(m map[string]int, k string) (int, error) {
+	v := m[k]
+	return v, nil
+}
+
+func assert(x interface{}) (string, error) {
+	s := x.(string)
+	return s, nil
+}
+
+func recv(results chan int) (int, error) {
+	v, ok := <-results
+	if !ok {
+		return 0, fmt.Errorf("receiving from results: channel closed")
+	}
+	return v, nil
+}
+
+func env() error {
+	os.LookupEnv("HOME")
+	return nil
+}
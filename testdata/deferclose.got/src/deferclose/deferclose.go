@@ -0,0 +1,12 @@
+package main
+
+import "os"
+
+func write(path string, b []byte) (int, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return f.Write(b)
+}
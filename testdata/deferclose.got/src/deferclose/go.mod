@@ -0,0 +1,3 @@
+module deferclose
+
+go 1.19
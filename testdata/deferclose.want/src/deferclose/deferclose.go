@@ -0,0 +1,16 @@
+package main
+
+import "os"
+
+func write(path string, b []byte) (_ int, err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	return f.Write(b)
+}
@@ -0,0 +1,12 @@
+package main
+
+import "os"
+
+func save(f *os.File) error {
+	err := f.Sync()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return nil
+}
@@ -0,0 +1,3 @@
+module deferclosedefine
+
+go 1.19
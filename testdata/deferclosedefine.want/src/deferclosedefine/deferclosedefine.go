@@ -0,0 +1,16 @@
+package main
+
+import "os"
+
+func save(f *os.File) (rerr error) {
+	err := f.Sync()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && rerr == nil {
+			rerr = cerr
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,8 @@
+package main
+
+import "os"
+
+func finish(f *os.File, err error) error {
+	defer f.Close()
+	return err
+}
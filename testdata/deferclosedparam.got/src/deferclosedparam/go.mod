@@ -0,0 +1,3 @@
+module deferclosedparam
+
+go 1.19
@@ -0,0 +1,12 @@
+package main
+
+import "os"
+
+func finish(f *os.File, err error) (rerr error) {
+	defer func() {
+		if cerr := f.Close(); cerr != nil && rerr == nil {
+			rerr = cerr
+		}
+	}()
+	return err
+}
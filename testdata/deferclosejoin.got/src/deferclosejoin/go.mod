@@ -0,0 +1,3 @@
+module deferclosejoin
+
+go 1.21
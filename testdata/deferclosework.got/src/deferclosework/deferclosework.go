@@ -0,0 +1,13 @@
+package main
+
+import "os"
+
+func save(path string, b []byte) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(b)
+	return err
+}
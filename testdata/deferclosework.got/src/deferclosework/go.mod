@@ -0,0 +1,3 @@
+module deferclosework
+
+go 1.19
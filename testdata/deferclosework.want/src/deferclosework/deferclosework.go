@@ -0,0 +1,18 @@
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func save(path string, b []byte) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, f.Close())
+	}()
+	_, err = f.Write(b)
+	return err
+}
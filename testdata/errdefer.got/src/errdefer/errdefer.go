@@ -0,0 +1,19 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+func cleanup(path string) error {
+	var err error
+	defer func() {
+		if err != nil {
+			log.Printf("cleanup %s: %v", path, err)
+		}
+	}()
+	if path != "" {
+		os.Remove(path)
+	}
+	return err
+}
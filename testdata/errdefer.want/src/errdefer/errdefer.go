@@ -0,0 +1,21 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+func cleanup(path string) error {
+	var err error
+	defer func() {
+		if err != nil {
+			log.Printf("cleanup %s: %v", path, err)
+		}
+	}()
+	if path != "" {
+		if err = os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,29 @@
+package main
+
+import "os"
+
+type ValidationError struct{ msg string }
+
+func (v *ValidationError) Error() string { return v.msg }
+
+type aliasErr = error
+
+func validate(path string) (int, *ValidationError) {
+	if path == "" {
+		return 0, &ValidationError{"empty path"}
+	}
+	return len(path), nil
+}
+
+func cleanup(path string) (bool, aliasErr) {
+	if err := os.Remove(path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func run(path string) error {
+	n := validate(path)
+	println(n)
+	return nil
+}
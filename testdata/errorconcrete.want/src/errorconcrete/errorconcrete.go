@@ -0,0 +1,30 @@
+package main
+
+import "os"
+
+type ValidationError struct{ msg string }
+
+func (v *ValidationError) Error() string { return v.msg }
+
+type aliasErr = error
+
+func validate(path string) (int, *ValidationError) {
+	if path == "" {
+		return 0, &ValidationError{"empty path"}
+	}
+	return len(path), nil
+}
+
+func cleanup(path string) (bool, aliasErr) {
+	os.Remove(path)
+	return true, nil
+}
+
+func run(path string) error {
+	n, err := validate(path)
+	if err != nil {
+		return err
+	}
+	println(n)
+	return nil
+}
@@ -0,0 +1,23 @@
+package main
+
+import "strconv"
+
+func validate(in string) string {
+	if in == "" {
+		return "empty input"
+	}
+	return ""
+}
+
+func parseAll(inputs []string) ([]int, error) {
+	var nums []int
+	for _, in := range inputs {
+		err := validate(in)
+		if err != "" {
+			continue
+		}
+		strconv.Atoi(in)
+		nums = append(nums, n)
+	}
+	return nums, nil
+}
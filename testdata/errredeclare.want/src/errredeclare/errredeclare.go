@@ -0,0 +1,26 @@
+package main
+
+import "strconv"
+
+func validate(in string) string {
+	if in == "" {
+		return "empty input"
+	}
+	return ""
+}
+
+func parseAll(inputs []string) ([]int, error) {
+	var nums []int
+	for _, in := range inputs {
+		err := validate(in)
+		if err != "" {
+			continue
+		}
+		n, err2 := strconv.Atoi(in)
+		if err2 != nil {
+			return nil, err2
+		}
+		nums = append(nums, n)
+	}
+	return nums, nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+func copyFile(dst io.Writer, path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n, _ := io.Copy(dst, f)
+	return n, nil
+}
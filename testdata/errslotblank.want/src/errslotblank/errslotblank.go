@@ -0,0 +1,19 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+func copyFile(dst io.Writer, path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n, err := io.Copy(dst, f)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,8 @@
+package main
+
+import "io"
+
+func writeAll(w io.Writer, b []byte) (int, error) {
+	n, werr := w.Write(b)
+	return n, nil
+}
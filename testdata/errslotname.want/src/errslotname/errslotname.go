@@ -0,0 +1,11 @@
+package main
+
+import "io"
+
+func writeAll(w io.Writer, b []byte) (int, error) {
+	n, werr := w.Write(b)
+	if werr != nil {
+		return 0, werr
+	}
+	return n, nil
+}
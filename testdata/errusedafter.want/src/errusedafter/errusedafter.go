@@ -0,0 +1,11 @@
+package main
+
+import "os"
+
+func sync(f *os.File) (int, error) {
+	err := f.Sync()
+	if err != nil {
+		return 0, err
+	}
+	return 0, err
+}
@@ -0,0 +1,11 @@
+package main
+
+import "os"
+
+var dir = "/tmp/cache"
+
+func init() {
+	os.MkdirAll(dir, 0755)
+}
+
+func main() {}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+var dir = "/tmp/cache"
+
+func init() {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		panic(fmt.Sprintf("mkdir all %q: %v", dir, err))
+	}
+}
+
+func main() {}
@@ -0,0 +1,7 @@
+package main
+
+import "os"
+
+func main() {
+	os.Remove("/tmp/state.bin")
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+func main() {
+	if err := os.Remove("/tmp/state.bin"); err != nil {
+		log.Fatal(err)
+	}
+}
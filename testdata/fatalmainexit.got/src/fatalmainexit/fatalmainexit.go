@@ -0,0 +1,14 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: fatalmainexit <path>")
+		os.Exit(2)
+	}
+	os.Remove(os.Args[1])
+}
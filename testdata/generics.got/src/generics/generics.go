@@ -0,0 +1,15 @@
+package main
+
+import "strconv"
+
+type number interface{ ~int | ~int64 }
+
+func Parse[T number](s string) (T, error) {
+	n, err := strconv.Atoi(s)
+	return T(n), err
+}
+
+func first[T any](items []T, raw string) (T, error) {
+	i := Parse[int](raw)
+	return items[i], nil
+}
@@ -0,0 +1,18 @@
+package main
+
+import "strconv"
+
+type number interface{ ~int | ~int64 }
+
+func Parse[T number](s string) (T, error) {
+	n, err := strconv.Atoi(s)
+	return T(n), err
+}
+
+func first[T any](items []T, raw string) (T, error) {
+	i, err := Parse[int](raw)
+	if err != nil {
+		return *new(T), err
+	}
+	return items[i], nil
+}
@@ -0,0 +1,12 @@
+package main
+
+type number interface{ ~int | ~int64 }
+
+func Convert[From, To number](f From) (To, error) {
+	return To(f), nil
+}
+
+func widen[T any](items []T, i int) (T, int64, error) {
+	n := Convert[int, int64](i)
+	return items[i], n, nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import "strconv"
+
+type Stack[T any] struct{ items []T }
+
+func (s *Stack[T]) Pop() (T, error) {
+	var zero T
+	if len(s.items) == 0 {
+		return zero, strconv.ErrRange
+	}
+	v := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return v, nil
+}
+
+func drain[T any](s *Stack[T]) (T, error) {
+	var zero T
+	v, err := s.Pop()
+	if err != nil {
+		return zero, err
+	}
+	for len(s.items) > 0 {
+		s.Pop()
+	}
+	return v, nil
+}
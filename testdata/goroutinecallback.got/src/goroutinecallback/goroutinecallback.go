@@ -0,0 +1,13 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+func cleanup(path string) {
+	go func() {
+		os.Remove(path)
+	}()
+	log.Printf("cleaning up %s", path)
+}
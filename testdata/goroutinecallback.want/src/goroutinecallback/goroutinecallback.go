@@ -0,0 +1,16 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+func cleanup(path string) {
+	go func() {
+		if err := os.Remove(path); err != nil {
+			log.Fatal(err)
+			return
+		}
+	}()
+	log.Printf("cleaning up %s", path)
+}
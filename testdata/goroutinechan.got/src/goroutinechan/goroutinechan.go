@@ -0,0 +1,19 @@
+package main
+
+import "os"
+
+func removeAll(paths []string) error {
+	errc := make(chan error, len(paths))
+	for _, path := range paths {
+		go func() {
+			os.Remove(path)
+			errc <- nil
+		}()
+	}
+	for range paths {
+		if err := <-errc; err != nil {
+			return err
+		}
+	}
+	return nil
+}
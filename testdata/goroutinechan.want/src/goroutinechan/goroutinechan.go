@@ -0,0 +1,22 @@
+package main
+
+import "os"
+
+func removeAll(paths []string) error {
+	errc := make(chan error, len(paths))
+	for _, path := range paths {
+		go func() {
+			if err := os.Remove(path); err != nil {
+				errc <- err
+				return
+			}
+			errc <- nil
+		}()
+	}
+	for range paths {
+		if err := <-errc; err != nil {
+			return err
+		}
+	}
+	return nil
+}
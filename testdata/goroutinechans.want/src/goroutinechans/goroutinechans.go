@@ -0,0 +1,28 @@
+package main
+
+import "os"
+
+func removeAll(paths []string) error {
+	abort := make(chan error, 1)
+	errc := make(chan error, len(paths))
+	for _, path := range paths {
+		go func() {
+			if err := os.Remove(path); err != nil {
+				errc <- err
+				return
+			}
+			errc <- nil
+		}()
+	}
+	for range paths {
+		select {
+		case err := <-errc:
+			if err != nil {
+				return err
+			}
+		case err := <-abort:
+			return err
+		}
+	}
+	return nil
+}
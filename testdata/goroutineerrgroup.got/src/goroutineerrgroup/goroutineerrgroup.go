@@ -0,0 +1,17 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sync/errgroup"
+)
+
+func removeAll(paths []string) error {
+	var eg errgroup.Group
+	for _, path := range paths {
+		go func() {
+			os.Remove(path)
+		}()
+	}
+	return eg.Wait()
+}
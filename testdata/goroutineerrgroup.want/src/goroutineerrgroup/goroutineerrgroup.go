@@ -0,0 +1,20 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sync/errgroup"
+)
+
+func removeAll(paths []string) error {
+	var eg errgroup.Group
+	for _, path := range paths {
+		eg.Go(func() error {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			return nil
+		})
+	}
+	return eg.Wait()
+}
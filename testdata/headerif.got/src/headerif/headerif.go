@@ -0,0 +1,12 @@
+package main
+
+func check(name string) (bool, error) {
+	return name != "", nil
+}
+
+func greet(name string) (string, error) {
+	if ok := check(name); ok {
+		return "hello " + name, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,14 @@
+package main
+
+func check(name string) (bool, error) {
+	return name != "", nil
+}
+
+func greet(name string) (string, error) {
+	if ok, err := check(name); err != nil {
+		return "", err
+	} else if ok {
+		return "hello " + name, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,13 @@
+package main
+
+func kind(x int) (string, error) {
+	return "small", nil
+}
+
+func describe(x int) (string, error) {
+	switch v := kind(x); v {
+	case "small":
+		return "a small number", nil
+	}
+	return "a number", nil
+}
@@ -0,0 +1,17 @@
+package main
+
+func kind(x int) (string, error) {
+	return "small", nil
+}
+
+func describe(x int) (string, error) {
+	v, err := kind(x)
+	if err != nil {
+		return "", err
+	}
+	switch v {
+	case "small":
+		return "a small number", nil
+	}
+	return "a number", nil
+}
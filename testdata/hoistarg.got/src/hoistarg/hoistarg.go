@@ -0,0 +1,18 @@
+package main
+
+import "strconv"
+
+type options struct {
+	verbose bool
+}
+
+func process(n int, opts options) error {
+	return nil
+}
+
+func run(s string, opts options) error {
+	if err := process(strconv.Atoi(s), opts); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import "strconv"
+
+type options struct {
+	verbose bool
+}
+
+func process(n int, opts options) error {
+	return nil
+}
+
+func run(s string, opts options) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	if err := process(n, opts); err != nil {
+		return err
+	}
+	return nil
+}
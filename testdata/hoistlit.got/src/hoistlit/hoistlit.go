@@ -0,0 +1,16 @@
+package main
+
+import (
+	"os"
+	"strconv"
+)
+
+type config struct {
+	Host string
+	Port int
+}
+
+func load() (*config, error) {
+	cfg := &config{Host: os.Getenv("HOST"), Port: strconv.Atoi(os.Getenv("PORT"))}
+	return cfg, nil
+}
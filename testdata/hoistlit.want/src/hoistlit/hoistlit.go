@@ -0,0 +1,20 @@
+package main
+
+import (
+	"os"
+	"strconv"
+)
+
+type config struct {
+	Host string
+	Port int
+}
+
+func load() (*config, error) {
+	n, err := strconv.Atoi(os.Getenv("PORT"))
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config{Host: os.Getenv("HOST"), Port: n}
+	return cfg, nil
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	format "fmt"
+	"net/http"
+)
+
+var greetings = map[string]string{"en": "hello"}
+
+func handle(rw http.ResponseWriter, r *http.Request) {
+	lang := r.FormValue("lang")
+	greeting := greetings[lang]
+	format.Fprintf(rw, "%s, world", greeting)
+}
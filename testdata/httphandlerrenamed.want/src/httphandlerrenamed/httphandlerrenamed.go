@@ -0,0 +1,18 @@
+package main
+
+import (
+	format "fmt"
+	"net/http"
+)
+
+var greetings = map[string]string{"en": "hello"}
+
+func handle(rw http.ResponseWriter, r *http.Request) {
+	lang := r.FormValue("lang")
+	greeting, ok := greetings[lang]
+	if !ok {
+		http.Error(rw, format.Sprintf("key %q not found", lang), http.StatusInternalServerError)
+		return
+	}
+	format.Fprintf(rw, "%s, world", greeting)
+}
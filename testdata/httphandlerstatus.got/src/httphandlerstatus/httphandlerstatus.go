@@ -0,0 +1,16 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type request struct {
+	Name string
+}
+
+func handle(rw http.ResponseWriter, r *http.Request) {
+	var req request
+	json.NewDecoder(r.Body).Decode(&req)
+	rw.Write([]byte("hello " + req.Name))
+}
@@ -0,0 +1,15 @@
+package main
+
+type scanner struct{}
+
+func (s *scanner) Scan() (bool, error) {
+	return false, nil
+}
+
+func count(s *scanner) (int, error) {
+	n := 0
+	for s.Scan() {
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,22 @@
+package main
+
+type scanner struct{}
+
+func (s *scanner) Scan() (bool, error) {
+	return false, nil
+}
+
+func count(s *scanner) (int, error) {
+	n := 0
+	for {
+		ok, err := s.Scan()
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,7 @@
+package lib
+
+type Config struct{}
+
+func Load(name string) (*Config, error) {
+	return &Config{}, nil
+}
@@ -0,0 +1,8 @@
+package main
+
+import "example.com/lib"
+
+func run() (*lib.Config, error) {
+	cfg := lib.Load("app.conf")
+	return cfg, nil
+}
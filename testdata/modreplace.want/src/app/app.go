@@ -0,0 +1,11 @@
+package main
+
+import "example.com/lib"
+
+func run() (*lib.Config, error) {
+	cfg, err := lib.Load("app.conf")
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
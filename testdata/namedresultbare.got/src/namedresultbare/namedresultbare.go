@@ -0,0 +1,22 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+type config struct{ path string }
+
+func load(path string) (cfg *config, err error) {
+	defer func() {
+		if err != nil {
+			log.Printf("loading %s: %v", path, err)
+		}
+	}()
+	os.Remove(path + ".lock")
+	if path != "" {
+		f := os.Open(path)
+		defer f.Close()
+	}
+	return &config{path: path}, nil
+}
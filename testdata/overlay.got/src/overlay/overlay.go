@@ -0,0 +1,5 @@
+package main
+
+func run() error {
+	return nil
+}
@@ -0,0 +1,4 @@
+package main
+
+// Config exists only in the overlay, not on disk.
+type Config struct{}
@@ -0,0 +1,5 @@
+package main
+
+func load(name string) (*Config, error) {
+	return &Config{}, nil
+}
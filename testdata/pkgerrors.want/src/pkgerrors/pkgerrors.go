@@ -0,0 +1,24 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+func load(path string) ([]byte, error) {
+	if path == "" {
+		return nil, errors.New("empty path")
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read file %q", path)
+	}
+	return b, nil
+}
+
+func cleanup(f *os.File) error {
+	f.Close()
+	return nil
+}
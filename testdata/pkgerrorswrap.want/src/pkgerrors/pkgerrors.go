@@ -0,0 +1,23 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+func load(path string) ([]byte, error) {
+	if path == "" {
+		return nil, errors.New("empty path")
+	}
+	b := ioutil.ReadFile(path)
+	return b, nil
+}
+
+func cleanup(f *os.File) error {
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "close")
+	}
+	return nil
+}
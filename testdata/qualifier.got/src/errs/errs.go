@@ -0,0 +1,5 @@
+package errs
+
+type Error struct{ Msg string }
+
+func (e *Error) Error() string { return e.Msg }
@@ -0,0 +1,5 @@
+package lib
+
+import "errs"
+
+func Check() (int, *errs.Error) { return 0, nil }
@@ -0,0 +1,12 @@
+package main
+
+import "lib"
+
+func run() (n int, err error) {
+	n, _ = lib.Check()
+	return n, nil
+}
+
+func main() {
+	run()
+}
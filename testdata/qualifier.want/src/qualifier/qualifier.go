@@ -0,0 +1,14 @@
+package main
+
+import "lib"
+
+func run() (n int, err error) {
+	if n, err = lib.Check(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func main() {
+	run()
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net"
+	"os"
+)
+
+type Server struct {
+	ln   net.Listener
+	addr string
+}
+
+func (s *Server) Start() error {
+	os.MkdirAll("/tmp/state", 0755)
+	if err := os.Remove("/tmp/state/lock"); err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.ln = ln
+	return nil
+}
+
+func main() {
+	s := &Server{addr: "localhost:8080"}
+	s.Start()
+}
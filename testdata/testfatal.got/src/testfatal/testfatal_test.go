@@ -0,0 +1,14 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRemove(t *testing.T) {
+	for _, path := range []string{"/tmp/a", "/tmp/b"} {
+		t.Run(path, func(t *testing.T) {
+			os.Remove(path)
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRemove(t *testing.T) {
+	for _, path := range []string{"/tmp/a", "/tmp/b"} {
+		t.Run(path, func(t *testing.T) {
+			if err := os.Remove(path); err != nil {
+				t.Fatalf("remove %q: %v", path, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	format "fmt"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	m := map[string]int{"a": 1}
+	v := m["a"]
+	format.Println(v)
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	format "fmt"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	m := map[string]int{"a": 1}
+	v, ok := m["a"]
+	if !ok {
+		t.Fatalf("key %q not found", "a")
+	}
+	format.Println(v)
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.bin")
+	f := os.Create(path)
+	require.NotNil(t, f)
+}
@@ -0,0 +1,10 @@
+package require
+
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+	FailNow()
+}
+
+func NoError(t TestingT, err error, msgAndArgs ...interface{}) {}
+
+func NotNil(t TestingT, object interface{}, msgAndArgs ...interface{}) {}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.bin")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NotNil(t, f)
+}
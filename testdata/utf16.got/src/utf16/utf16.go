@@ -0,0 +1,12 @@
+package main
+
+import "os"
+
+func cleanup() error {
+	os.Remove("/tmp/😀/ü")
+	return nil
+}
+
+func main() {
+	cleanup()
+}
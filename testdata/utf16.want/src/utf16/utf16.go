@@ -0,0 +1,14 @@
+package main
+
+import "os"
+
+func cleanup() error {
+	if err := os.Remove("/tmp/😀/ü"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func main() {
+	cleanup()
+}
@@ -0,0 +1,18 @@
+package main
+
+type Config struct {
+	Name string
+}
+
+func load(path string) (*Config, error) {
+	return &Config{}, nil
+}
+
+func name(path string) (string, error) {
+	var (
+		prefix = "config: "
+		cfg    = load(path)
+		suffix = "."
+	)
+	return prefix + cfg.Name + suffix, nil
+}
@@ -0,0 +1,23 @@
+package main
+
+type Config struct {
+	Name string
+}
+
+func load(path string) (*Config, error) {
+	return &Config{}, nil
+}
+
+func name(path string) (string, error) {
+	var (
+		prefix = "config: "
+	)
+	var cfg, err = load(path)
+	if err != nil {
+		return "", err
+	}
+	var (
+		suffix = "."
+	)
+	return prefix + cfg.Name + suffix, nil
+}
@@ -0,0 +1,21 @@
+package main
+
+type Config struct {
+	Name string
+}
+
+func load(path string) (*Config, error) {
+	return &Config{}, nil
+}
+
+func name(path string) (string, error) {
+	// Parts of the name.
+	var (
+		// prefix precedes the name.
+		prefix = "config: " // with a space
+		// cfg is loaded from path.
+		cfg    = load(path) // may fail
+		suffix = "."        // full stop
+	)
+	return prefix + cfg.Name + suffix, nil
+}
@@ -0,0 +1,8 @@
+package main
+
+import "os"
+
+func cleanup(path string) error {
+	var e error = os.Remove(path)
+	return e
+}
@@ -0,0 +1,11 @@
+package main
+
+import "os"
+
+func cleanup(path string) error {
+	var e error = os.Remove(path)
+	if e != nil {
+		return e
+	}
+	return e
+}
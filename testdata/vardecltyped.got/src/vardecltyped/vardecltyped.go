@@ -0,0 +1,13 @@
+package main
+
+import "os"
+
+func size(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var n int64 = f.Seek(0, 2)
+	return n, nil
+}
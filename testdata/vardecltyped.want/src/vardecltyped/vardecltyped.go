@@ -0,0 +1,17 @@
+package main
+
+import "os"
+
+func size(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var n int64
+	n, err = f.Seek(0, 2)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+func logic(path string) (int, error) {
+	os.Remove(path)
+	return 0, nil
+}
+
+func create(path string, n int) (*os.File, error) {
+	f := os.OpenFile(path, os.O_CREATE, os.FileMode(n))
+	return f, nil
+}
+
+func main() {
+	if _, err := logic("/tmp/foo"); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+func logic(path string) (int, error) {
+	if err := os.Remove(path); err != nil {
+		return 0, fmt.Errorf("remove %q: %w", path, err)
+	}
+	return 0, nil
+}
+
+func create(path string, n int) (*os.File, error) {
+	f := os.OpenFile(path, os.O_CREATE, os.FileMode(n))
+	return f, nil
+}
+
+func main() {
+	if _, err := logic("/tmp/foo"); err != nil {
+		log.Fatal(err)
+	}
+}
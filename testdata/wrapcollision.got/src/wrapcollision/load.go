@@ -0,0 +1,10 @@
+package main
+
+import "github.com/pkg/errors"
+
+func load(path string) error {
+	if path == "" {
+		return errors.New("empty path")
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+package main
+
+import "errors"
+
+var errSkipped = errors.New("skipped")
+
+func run(path string) error {
+	load(path)
+	return errSkipped
+}
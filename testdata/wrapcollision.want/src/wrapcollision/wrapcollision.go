@@ -0,0 +1,15 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errSkipped = errors.New("skipped")
+
+func run(path string) error {
+	if err := load(path); err != nil {
+		return fmt.Errorf("load %q: %w", path, err)
+	}
+	return errSkipped
+}
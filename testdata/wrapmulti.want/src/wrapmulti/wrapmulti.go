@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+func logic(path string) (int, error) {
+	os.Remove(path)
+	return 0, nil
+}
+
+func create(path string, n int) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE, os.FileMode(n))
+	if err != nil {
+		return nil, fmt.Errorf("open file %q: %w", path, err)
+	}
+	return f, nil
+}
+
+func main() {
+	if _, err := logic("/tmp/foo"); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	tm "time"
+)
+
+type point struct{ x, y int }
+
+type alias = point
+
+func lookup() (map[string]int, chan int, func(), tm.Duration, bytes.Buffer, [4]point, alias, *os.File, error) {
+	os.Remove("/tmp/foo")
+	return nil, nil, nil, 0, bytes.Buffer{}, [4]point{}, point{}, nil, nil
+}
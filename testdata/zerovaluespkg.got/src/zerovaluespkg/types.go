@@ -0,0 +1,5 @@
+package zerovaluespkg
+
+type config struct {
+	path string
+}
@@ -0,0 +1,8 @@
+package zerovaluespkg
+
+import "os"
+
+func load(path string) (config, error) {
+	fi := os.Stat(path)
+	return config{path: path}, nil
+}
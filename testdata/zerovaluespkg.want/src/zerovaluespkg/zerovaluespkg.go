@@ -0,0 +1,11 @@
+package zerovaluespkg
+
+import "os"
+
+func load(path string) (config, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return config{}, err
+	}
+	return config{path: path}, nil
+}
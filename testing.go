@@ -0,0 +1,139 @@
+package main
+
+// This file defines how errors are handled within tests, benchmarks and
+// fuzz tests.
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path"
+	"strconv"
+)
+
+const requirePath = "github.com/stretchr/testify/require"
+
+// testingVar returns the *testing.T, *testing.B, *testing.F or testing.TB
+// which is in scope at the cursor, if any. Inner scopes are preferred, e.g. the
+// *testing.T of a closure passed to t.Run.
+func (e *expansion) testingVar() *types.Var {
+	return e.inScope(func(typ types.Type) bool {
+		if _, ok := types.Unalias(typ).(*types.Pointer); ok {
+			return isNamed(typ, "testing", "T") || isNamed(typ, "testing", "B") || isNamed(typ, "testing", "F")
+		}
+		return isNamed(typ, "testing", "TB")
+	})
+}
+
+// testOutput returns the statements failing the test tb with errExpr, e.g.
+// t.Fatalf("remove %q: %v", path, err). Within goroutines, which must not call
+// t.Fatal, the failure is reported using t.Error instead.
+func (e *expansion) testOutput(tb *types.Var, errExpr ast.Expr) []ast.Stmt {
+	method := func(name string) ast.Expr {
+		return &ast.SelectorExpr{X: &ast.Ident{Name: tb.Name()}, Sel: &ast.Ident{Name: name}}
+	}
+	if gs, _ := e.goStmt(); gs != nil {
+		return []ast.Stmt{
+			&ast.ExprStmt{X: &ast.CallExpr{Fun: method("Error"), Args: []ast.Expr{errExpr}}},
+			&ast.ReturnStmt{},
+		}
+	}
+
+	call := &ast.CallExpr{Fun: method("Fatal"), Args: []ast.Expr{errExpr}}
+	if ce, ok := errExpr.(*ast.CallExpr); ok && e.isPkgFunc(ce.Fun, "fmt", "Errorf") {
+		// e.g. fmt.Errorf("key %q not found", k) → t.Fatalf("key %q not found", k)
+		call = &ast.CallExpr{Fun: method("Fatalf"), Args: ce.Args}
+	} else if e.ce != nil && !*wrapFlag {
+		if msg, args := e.wrapContext(); msg != "" {
+			call = &ast.CallExpr{
+				Fun: method("Fatalf"),
+				Args: append(append([]ast.Expr{
+					&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(msg + ": %v")},
+				}, args...), errExpr),
+			}
+		}
+	}
+	return []ast.Stmt{&ast.ExprStmt{X: call}}
+}
+
+// isPkgFunc returns whether fun refers to the function name in the package
+// with the specified import path, e.g. fmt.Errorf.
+func (e *expansion) isPkgFunc(fun ast.Expr, importPath, name string) bool {
+	sel, ok := fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != name {
+		return false
+	}
+	id, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	if obj, ok := e.info.Uses[id]; ok {
+		pkgName, ok := obj.(*types.PkgName)
+		return ok && unvendor(pkgName.Imported().Path()) == importPath
+	}
+	// fun was generated by qualify, which refers to the package by the name
+	// under which the file under cursor imports it.
+	local, ok := e.importedAs(importPath, path.Base(importPath))
+	return ok && id.Name == local
+}
+
+// requireNoError replaces the error checks (if err != nil { … }) in repl with
+// require.NoError(t, err) if the file under cursor uses testify’s require
+// package and the cursor is within a test. Otherwise, repl is returned as-is.
+func (e *expansion) requireNoError(errName string, repl []ast.Node) []ast.Node {
+	if !e.imported(requirePath) {
+		return repl
+	}
+	if _, returnsError := errorResult(e.callerSig); returnsError {
+		return repl
+	}
+	if gs, _ := e.goStmt(); gs != nil {
+		return repl // require.NoError must not be called within goroutines
+	}
+	tb := e.testingVar()
+	if tb == nil {
+		return repl
+	}
+	noError := func(x ast.Expr) ast.Stmt {
+		return &ast.ExprStmt{X: &ast.CallExpr{
+			Fun:  e.qualify(requirePath, "NoError"),
+			Args: []ast.Expr{&ast.Ident{Name: tb.Name()}, x},
+		}}
+	}
+
+	var result []ast.Node
+	for _, n := range repl {
+		is, ok := n.(*ast.IfStmt)
+		if !ok {
+			result = append(result, n)
+			continue
+		}
+		init, ok := is.Init.(*ast.AssignStmt)
+		if !ok {
+			// e.g. if err != nil { … } → require.NoError(t, err)
+			result = append(result, noError(&ast.Ident{Name: errName}))
+			continue
+		}
+		if len(init.Lhs) == 1 && init.Tok == token.DEFINE {
+			// e.g. if err := os.Remove(…); err != nil { … } →
+			// require.NoError(t, os.Remove(…))
+			result = append(result, noError(init.Rhs[0]))
+			continue
+		}
+		// e.g. if _, err := f.Write(…); err != nil { … } → _, err :=
+		// f.Write(…); require.NoError(t, err)
+		if scope := e.getScope(); init.Tok == token.DEFINE && scope != nil && lookup(scope, errName, e.path[0].Pos()) != nil {
+			onlyErr := true
+			for _, lhs := range init.Lhs {
+				if id, ok := lhs.(*ast.Ident); !ok || (id.Name != "_" && id.Name != errName) {
+					onlyErr = false
+				}
+			}
+			if onlyErr {
+				init.Tok = token.ASSIGN // err is already declared
+			}
+		}
+		result = append(result, init, noError(&ast.Ident{Name: errName}))
+	}
+	return result
+}
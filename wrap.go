@@ -0,0 +1,182 @@
+package main
+
+// This file defines how returned errors are wrapped with context.
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// calleeName returns the name of the function or method called by ce, or ""
+// if the callee has no name (e.g. a function literal).
+func calleeName(ce *ast.CallExpr) string {
+	switch fun := unparen(ce.Fun).(type) {
+	case *ast.Ident:
+		return fun.Name
+	case *ast.SelectorExpr:
+		return fun.Sel.Name
+	}
+	return ""
+}
+
+// splitCamelCase splits a Go identifier into lower-case words, e.g.
+// "MkdirAll" → "mkdir all" and "ParseURL" → "parse url".
+func splitCamelCase(name string) string {
+	runes := []rune(name)
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		if !unicode.IsUpper(runes[i]) {
+			continue
+		}
+		// Split before an upper-case letter following a lower-case one
+		// (“mkdirAll”) and before the last letter of an acronym which starts
+		// a new word (“URLPath”).
+		if unicode.IsLower(runes[i-1]) ||
+			(i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsLower(runes[i+1])) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	words = append(words, string(runes[start:]))
+	return strings.ToLower(strings.Join(words, " "))
+}
+
+// formatVerb returns the fmt verb with which an argument of type typ is
+// included in an error message, or "" if the argument should be left out.
+func formatVerb(typ types.Type) string {
+	basic, ok := typ.Underlying().(*types.Basic)
+	if !ok {
+		return ""
+	}
+	switch {
+	case basic.Info()&types.IsString != 0:
+		return "%q"
+	case basic.Info()&(types.IsNumeric|types.IsBoolean) != 0:
+		return "%v"
+	}
+	return ""
+}
+
+// wrapContext derives a message describing e.ce from the callee’s name and
+// its simple arguments (string literals and identifiers of basic type). It
+// returns the message as a fmt format string (without a trailing verb for the
+// error itself) and the corresponding arguments, or "" if no context could be
+// derived.
+func (e *expansion) wrapContext() (string, []ast.Expr) {
+	var verbs []string
+	var args []ast.Expr
+	for _, arg := range e.ce.Args {
+		switch arg := unparen(arg).(type) {
+		case *ast.BasicLit:
+			if arg.Kind == token.STRING {
+				verbs = append(verbs, "%q")
+				args = append(args, arg)
+			}
+		case *ast.Ident:
+			obj := e.info.Uses[arg]
+			switch obj.(type) {
+			case *types.Var, *types.Const:
+			default:
+				continue // e.g. nil, true, or a type-checking error
+			}
+			if verb := formatVerb(obj.Type()); verb != "" {
+				verbs = append(verbs, verb)
+				args = append(args, arg)
+			}
+		}
+	}
+	msg := splitCamelCase(calleeName(e.ce))
+	if len(verbs) > 0 {
+		msg = strings.TrimSpace(msg + " " + strings.Join(verbs, " "))
+	}
+	return msg, args
+}
+
+// wrapPackages lists the error wrapping packages which are used instead of the
+// standard library if the package under cursor already uses them.
+var wrapPackages = []struct {
+	path  string
+	wrapf bool // Wrap(err, msg) and Wrapf(err, format, args...) instead of Errorf
+}{
+	{"github.com/pkg/errors", true},
+	{"github.com/cockroachdb/errors", true},
+	{"emperror.dev/errors", true},
+	{"golang.org/x/xerrors", false},
+}
+
+// wrapPackage returns the import path of the package whose functions wrap
+// errors: a package from wrapPackages imported by the file under cursor or,
+// failing that, by its package, unless importing it into the file would
+// collide with a name already in use (e.g. the standard library’s errors).
+// Defaults to the standard library’s fmt.
+func (e *expansion) wrapPackage() (path string, wrapf bool) {
+	for _, wp := range wrapPackages {
+		if e.imported(wp.path) {
+			return wp.path, wp.wrapf
+		}
+	}
+	if e.pkg != nil {
+		for _, imp := range e.pkg.Imports() {
+			p := unvendor(imp.Path())
+			for _, wp := range wrapPackages {
+				if p == wp.path && !e.nameTaken(imp.Name()) {
+					return wp.path, wp.wrapf
+				}
+			}
+		}
+	}
+	return "fmt", false
+}
+
+// nameTaken returns whether name is declared in the file under cursor or its
+// package, e.g. by an import of another package with the same name.
+func (e *expansion) nameTaken(name string) bool {
+	for _, imp := range e.file.Imports {
+		if imp.Name != nil {
+			if imp.Name.Name == name {
+				return true
+			}
+			continue
+		}
+		if p, err := strconv.Unquote(imp.Path.Value); err == nil && path.Base(p) == name {
+			return true
+		}
+	}
+	return e.pkg.Scope().Lookup(name) != nil
+}
+
+// wrapErr returns an expression wrapping errExpr with context about e.ce, e.g.
+// fmt.Errorf("remove %q: %w", path, err) or errors.Wrapf(err, "remove %q",
+// path). If no context can be derived, errExpr is returned unchanged.
+func (e *expansion) wrapErr(errExpr ast.Expr) ast.Expr {
+	msg, args := e.wrapContext()
+	if msg == "" {
+		return errExpr
+	}
+	path, wrapf := e.wrapPackage()
+	if !wrapf {
+		return &ast.CallExpr{
+			Fun: e.qualify(path, "Errorf"),
+			Args: append(append([]ast.Expr{
+				&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(msg + ": %w")},
+			}, args...), errExpr),
+		}
+	}
+	fun := "Wrap"
+	if len(args) > 0 {
+		fun = "Wrapf"
+	}
+	return &ast.CallExpr{
+		Fun: e.qualify(path, fun),
+		Args: append([]ast.Expr{
+			errExpr,
+			&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(msg)},
+		}, args...),
+	}
+}